@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/backend/backendtest"
+)
+
+// countingBackend counts the lookups that reach the backend.
+type countingBackend struct {
+	*backend.MemoryBackend
+	gets atomic.Int32
+}
+
+func newCountingBackend(passwords map[string]string) *countingBackend {
+	return &countingBackend{MemoryBackend: backend.NewMemoryBackend(passwords)}
+}
+
+func (b *countingBackend) GetPassword(account string) (string, error) {
+	b.gets.Add(1)
+	return b.MemoryBackend.GetPassword(account)
+}
+
+func startAgent(t *testing.T, b backend.Backend, idle time.Duration) (string, chan error) {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	l, err := Listen(socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	factory := func(provider, vault, tokenKey string) (backend.Backend, error) {
+		if provider != "memory" {
+			return nil, fmt.Errorf("unknown backend: %s", provider)
+		}
+		return b, nil
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- NewServer(factory, time.Minute, idle).Serve(l) }()
+	t.Cleanup(func() { l.Close() })
+	return socket, done
+}
+
+func TestClientServer(t *testing.T) {
+	t.Parallel()
+
+	b := newCountingBackend(map[string]string{"A": "1", "B": "2"})
+	socket, _ := startAgent(t, b, 0)
+
+	c, err := NewClient(socket, "memory", "", "")
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		v, err := c.GetPassword("A")
+		if err != nil || v != "1" {
+			t.Fatalf("get: %q, %v", v, err)
+		}
+	}
+	if gets := b.gets.Load(); gets != 1 {
+		t.Fatalf("expected cached second get, backend saw %d gets", gets)
+	}
+
+	values, err := c.GetMultiplePasswords([]string{"A", "B", "MISSING"})
+	if err != nil {
+		t.Fatalf("get multiple: %v", err)
+	}
+	if len(values) != 2 || values["A"] != "1" || values["B"] != "2" {
+		t.Fatalf("unexpected values %v", values)
+	}
+
+	if _, err := c.GetPassword("MISSING"); !errors.Is(err, backend.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := c.SetPassword("A", "updated", true); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if v, err := c.GetPassword("A"); err != nil || v != "updated" {
+		t.Fatalf("expected cache to be invalidated, got %q, %v", v, err)
+	}
+
+	other, err := NewClient(socket, "nope", "", "")
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := other.List(); err == nil {
+		t.Fatalf("expected error for unknown provider")
+	}
+}
+
+func TestClientConformance(t *testing.T) {
+	t.Parallel()
+
+	backendtest.Run(t, func(t *testing.T) backend.Backend {
+		socket, _ := startAgent(t, backend.NewMemoryBackend(nil), 0)
+		c, err := NewClient(socket, "memory", "", "")
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		return c
+	})
+}
+
+func TestServerIdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	_, done := startAgent(t, backend.NewMemoryBackend(nil), 50*time.Millisecond)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("agent did not exit after idle timeout")
+	}
+}
+
+func TestNewClientWithoutAgent(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewClient(filepath.Join(t.TempDir(), "missing.sock"), "memory", "", ""); err == nil {
+		t.Fatalf("expected error without agent")
+	}
+}
+
+func TestServerSeparatesTokenKeys(t *testing.T) {
+	t.Parallel()
+
+	socket := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	l, err := Listen(socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	var created atomic.Int32
+	factory := func(provider, vault, tokenKey string) (backend.Backend, error) {
+		created.Add(1)
+		return backend.NewMemoryBackend(map[string]string{"TOKEN": "from " + tokenKey}), nil
+	}
+	go NewServer(factory, time.Minute, 0).Serve(l)
+
+	for _, tokenKey := range []string{"OP_TOKEN_A", "OP_TOKEN_B", "OP_TOKEN_A"} {
+		c, err := NewClient(socket, "1password", "chainenv", tokenKey)
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		if v, err := c.GetPassword("TOKEN"); err != nil || v != "from "+tokenKey {
+			t.Fatalf("get with %s: %q, %v", tokenKey, v, err)
+		}
+	}
+	if n := created.Load(); n != 2 {
+		t.Fatalf("expected one backend per token key, factory was called %d times", n)
+	}
+}
@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/dvcrn/chainenv/backend"
+)
+
+const dialTimeout = 2 * time.Second
+
+// Client is a backend.Backend that forwards every call to an agent.
+type Client struct {
+	socket   string
+	provider string
+	vault    string
+	tokenKey string
+}
+
+var _ backend.Backend = (*Client)(nil)
+
+// NewClient returns a client for provider served by the agent at socket.
+// tokenKey is the 1Password service account token key configured for the
+// caller, if any. It fails if the agent can't be reached, so callers can fall
+// back to using the backend directly.
+func NewClient(socket, provider, vault, tokenKey string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", socket, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect to agent: %w", err)
+	}
+	conn.Close()
+	return &Client{socket: socket, provider: provider, vault: vault, tokenKey: tokenKey}, nil
+}
+
+func (c *Client) GetPassword(account string) (string, error) {
+	resp, err := c.do(Request{Op: OpGet, Account: account})
+	if err != nil {
+		return "", err
+	}
+	return resp.Value, nil
+}
+
+func (c *Client) SetPassword(account, password string, update bool) error {
+	_, err := c.do(Request{Op: OpSet, Account: account, Password: password, Update: update})
+	return err
+}
+
+func (c *Client) List() ([]string, error) {
+	resp, err := c.do(Request{Op: OpList})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Accounts, nil
+}
+
+func (c *Client) GetMultiplePasswords(accounts []string) (map[string]string, error) {
+	resp, err := c.do(Request{Op: OpGetMultiple, Accounts: accounts})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Values == nil {
+		resp.Values = make(map[string]string)
+	}
+	return resp.Values, nil
+}
+
+func (c *Client) DeletePassword(account string) error {
+	_, err := c.do(Request{Op: OpDelete, Account: account})
+	return err
+}
+
+func (c *Client) do(req Request) (*Response, error) {
+	req.Provider = c.provider
+	req.Vault = c.vault
+	req.TokenKey = c.tokenKey
+
+	conn, err := net.DialTimeout("unix", c.socket, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect to agent: %w", err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("send request to agent: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("read response from agent: %w", err)
+	}
+
+	if resp.Error != "" {
+		return nil, &remoteError{msg: resp.Error, notFound: resp.NotFound}
+	}
+	return &resp, nil
+}
+
+// remoteError is an error returned by the agent. It wraps backend.ErrNotFound
+// if the agent reported a missing secret.
+type remoteError struct {
+	msg      string
+	notFound bool
+}
+
+func (e *remoteError) Error() string {
+	return e.msg
+}
+
+func (e *remoteError) Unwrap() error {
+	if e.notFound {
+		return backend.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package agent
+
+// Operations supported by the agent.
+const (
+	OpGet         = "get"
+	OpGetMultiple = "get-multiple"
+	OpList        = "list"
+	OpSet         = "set"
+	OpDelete      = "delete"
+)
+
+// SockEnv is the environment variable clients use to find the agent.
+const SockEnv = "CHAINENV_AGENT_SOCK"
+
+// Request is sent by clients as a single JSON line.
+type Request struct {
+	Op       string `json:"op"`
+	Provider string `json:"provider"`
+	Vault    string `json:"vault,omitempty"`
+	// TokenKey is the 1Password service_account_token_key configured in
+	// the client's project, so the agent uses the same account.
+	TokenKey string   `json:"token_key,omitempty"`
+	Account  string   `json:"account,omitempty"`
+	Accounts []string `json:"accounts,omitempty"`
+	Password string   `json:"password,omitempty"`
+	Update   bool     `json:"update,omitempty"`
+}
+
+// Response is sent by the agent as a single JSON line.
+type Response struct {
+	Value    string            `json:"value,omitempty"`
+	Values   map[string]string `json:"values,omitempty"`
+	Accounts []string          `json:"accounts,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	// NotFound is set if Error is caused by a missing secret.
+	NotFound bool `json:"not_found,omitempty"`
+}
@@ -0,0 +1,287 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/fsutil"
+)
+
+// Factory creates the backend serving provider. vault and tokenKey, the
+// client's 1Password service account token key, are only meaningful for the
+// 1password provider. The server never calls it concurrently.
+type Factory func(provider, vault, tokenKey string) (backend.Backend, error)
+
+type cacheEntry struct {
+	value   string
+	expires time.Time
+}
+
+type listEntry struct {
+	accounts []string
+	expires  time.Time
+}
+
+// Server holds initialized backends and caches their results.
+type Server struct {
+	factory     Factory
+	cacheTTL    time.Duration
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	backends map[string]backend.Backend
+	values   map[string]cacheEntry
+	lists    map[string]listEntry
+}
+
+// NewServer returns a server that creates backends with factory, caches
+// values for cacheTTL (0 disables caching) and stops serving after no request
+// arrived for idleTimeout (0 disables the timeout).
+func NewServer(factory Factory, cacheTTL, idleTimeout time.Duration) *Server {
+	return &Server{
+		factory:     factory,
+		cacheTTL:    cacheTTL,
+		idleTimeout: idleTimeout,
+		backends:    make(map[string]backend.Backend),
+		values:      make(map[string]cacheEntry),
+		lists:       make(map[string]listEntry),
+	}
+}
+
+// DefaultSocketPath returns the socket path used when none is configured.
+func DefaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "chainenv-agent", "agent.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("chainenv-agent-%d", os.Getuid()), "agent.sock")
+}
+
+// Listen creates a Unix socket at path that only the current user can
+// connect to. A stale socket left behind by a previous agent is replaced.
+func Listen(path string) (net.Listener, error) {
+	if err := fsutil.MkdirPrivate(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("an agent is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve accepts connections on l until l is closed or the idle timeout
+// expires. It closes l before returning.
+func (s *Server) Serve(l net.Listener) error {
+	defer l.Close()
+
+	var idle *time.Timer
+	var timedOut bool
+	var timeoutMu sync.Mutex
+	if s.idleTimeout > 0 {
+		idle = time.AfterFunc(s.idleTimeout, func() {
+			timeoutMu.Lock()
+			timedOut = true
+			timeoutMu.Unlock()
+			l.Close()
+		})
+		defer idle.Stop()
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			timeoutMu.Lock()
+			defer timeoutMu.Unlock()
+			if timedOut || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		if idle != nil {
+			idle.Reset(s.idleTimeout)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handleConn(conn)
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req Request
+		var resp Response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = fmt.Sprintf("invalid request: %v", err)
+		} else {
+			resp = s.handle(req)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handle(req Request) Response {
+	scope := req.Provider + "\x00" + req.Vault + "\x00" + req.TokenKey
+	b, err := s.backend(scope, req)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	switch req.Op {
+	case OpGet:
+		if value, ok := s.cached(scope, req.Account); ok {
+			return Response{Value: value}
+		}
+		value, err := b.GetPassword(req.Account)
+		if err != nil {
+			return errorResponse(err)
+		}
+		s.store(scope, req.Account, value)
+		return Response{Value: value}
+	case OpGetMultiple:
+		values := make(map[string]string)
+		var missing []string
+		for _, account := range req.Accounts {
+			if value, ok := s.cached(scope, account); ok {
+				values[account] = value
+			} else {
+				missing = append(missing, account)
+			}
+		}
+		if len(missing) > 0 {
+			fetched, err := b.GetMultiplePasswords(missing)
+			if err != nil {
+				return errorResponse(err)
+			}
+			for account, value := range fetched {
+				s.store(scope, account, value)
+				values[account] = value
+			}
+		}
+		return Response{Values: values}
+	case OpList:
+		if accounts, ok := s.cachedList(scope); ok {
+			return Response{Accounts: accounts}
+		}
+		accounts, err := b.List()
+		if err != nil {
+			return errorResponse(err)
+		}
+		s.storeList(scope, accounts)
+		return Response{Accounts: accounts}
+	case OpSet:
+		s.invalidate(scope, req.Account)
+		if err := b.SetPassword(req.Account, req.Password, req.Update); err != nil {
+			return errorResponse(err)
+		}
+		return Response{}
+	case OpDelete:
+		s.invalidate(scope, req.Account)
+		if err := b.DeletePassword(req.Account); err != nil {
+			return errorResponse(err)
+		}
+		return Response{}
+	default:
+		return Response{Error: fmt.Sprintf("unknown operation: %s", req.Op)}
+	}
+}
+
+// backend returns the backend serving req, creating it on first use. Backends
+// are shared by all requests with the same scope.
+func (s *Server) backend(scope string, req Request) (backend.Backend, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.backends[scope]; ok {
+		return b, nil
+	}
+	b, err := s.factory(req.Provider, req.Vault, req.TokenKey)
+	if err != nil {
+		return nil, err
+	}
+	s.backends[scope] = b
+	return b, nil
+}
+
+func (s *Server) cached(scope, account string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.values[scope+"\x00"+account]
+	if !ok || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.value, true
+}
+
+func (s *Server) store(scope, account, value string) {
+	if s.cacheTTL <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[scope+"\x00"+account] = cacheEntry{value: value, expires: time.Now().Add(s.cacheTTL)}
+}
+
+func (s *Server) cachedList(scope string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.lists[scope]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.accounts, true
+}
+
+func (s *Server) storeList(scope string, accounts []string) {
+	if s.cacheTTL <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lists[scope] = listEntry{accounts: accounts, expires: time.Now().Add(s.cacheTTL)}
+}
+
+func (s *Server) invalidate(scope, account string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, scope+"\x00"+account)
+	delete(s.lists, scope)
+}
+
+func errorResponse(err error) Response {
+	return Response{Error: err.Error(), NotFound: errors.Is(err, backend.ErrNotFound)}
+}
@@ -0,0 +1,271 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dvcrn/chainenv/backend"
+)
+
+// PathEnv is the environment variable enabling the audit log. It holds the
+// path of the log file.
+const PathEnv = "CHAINENV_AUDIT_LOG"
+
+// Operations recorded in the audit log.
+const (
+	OpGet         = "get"
+	OpGetMultiple = "get-multiple"
+	OpSet         = "set"
+	OpUpdate      = "update"
+	OpList        = "list"
+	OpDelete      = "delete"
+)
+
+// Outcomes recorded in the audit log.
+const (
+	OutcomeOK       = "ok"
+	OutcomeNotFound = "not-found"
+	OutcomeError    = "error"
+)
+
+// Entry is a single line of the audit log. It never contains secret values.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Op       string    `json:"op"`
+	Key      string    `json:"key,omitempty"`
+	Keys     []string  `json:"keys,omitempty"`
+	Provider string    `json:"provider"`
+	Command  string    `json:"command,omitempty"`
+	Cwd      string    `json:"cwd,omitempty"`
+	PID      int       `json:"pid"`
+	PPID     int       `json:"ppid"`
+	Parent   string    `json:"parent,omitempty"`
+	Outcome  string    `json:"outcome"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// HasKey reports whether the entry concerns key.
+func (e Entry) HasKey(key string) bool {
+	return e.Key == key || slices.Contains(e.Keys, key)
+}
+
+// Log is an append-only JSON lines audit log.
+type Log struct {
+	path string
+}
+
+// Open returns the log at path. The file is created on first write.
+func Open(path string) *Log {
+	return &Log{path: path}
+}
+
+// FromEnv returns the log configured through PathEnv, or nil if auditing is
+// disabled.
+func FromEnv() *Log {
+	path := os.Getenv(PathEnv)
+	if path == "" {
+		return nil
+	}
+	return Open(path)
+}
+
+// Append writes entry as a single line. The file is opened in append mode
+// with 0600 permissions, so concurrent writers don't clobber each other.
+func (l *Log) Append(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Filter selects entries when reading the log. Zero fields match everything.
+type Filter struct {
+	Key   string
+	Op    string
+	Since time.Time
+	Until time.Time
+}
+
+func (f Filter) match(e Entry) bool {
+	if f.Key != "" && !e.HasKey(f.Key) {
+		return false
+	}
+	if f.Op != "" && e.Op != f.Op {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Read returns all entries in the log matching filter, oldest first.
+func (l *Log) Read(filter Filter) ([]Entry, error) {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", l.path, line, err)
+		}
+		if filter.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Context describes the process accessing secrets.
+type Context struct {
+	Command string
+	Cwd     string
+	PID     int
+	PPID    int
+	Parent  string
+}
+
+// CurrentContext returns the context of the running process. command is the
+// chainenv command being run.
+func CurrentContext(command string) Context {
+	cwd, _ := os.Getwd()
+	ppid := os.Getppid()
+	return Context{
+		Command: command,
+		Cwd:     cwd,
+		PID:     os.Getpid(),
+		PPID:    ppid,
+		Parent:  processName(ppid),
+	}
+}
+
+// processName returns the executable name of pid, or "" if it can't be
+// determined.
+func processName(pid int) string {
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	out, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// auditedBackend records every call to the wrapped backend in the log.
+type auditedBackend struct {
+	backend  backend.Backend
+	log      *Log
+	provider string
+	ctx      Context
+	onErr    func(error)
+}
+
+// Wrap returns a backend recording every operation on b in log. Failures to
+// write the log are passed to onErr and don't fail the operation.
+func Wrap(b backend.Backend, log *Log, provider string, ctx Context, onErr func(error)) backend.Backend {
+	return &auditedBackend{backend: b, log: log, provider: provider, ctx: ctx, onErr: onErr}
+}
+
+// Unwrap returns the wrapped backend.
+func (a *auditedBackend) Unwrap() backend.Backend {
+	return a.backend
+}
+
+func (a *auditedBackend) record(entry Entry, err error) {
+	entry.Time = time.Now().UTC()
+	entry.Provider = a.provider
+	entry.Command = a.ctx.Command
+	entry.Cwd = a.ctx.Cwd
+	entry.PID = a.ctx.PID
+	entry.PPID = a.ctx.PPID
+	entry.Parent = a.ctx.Parent
+
+	switch {
+	case err == nil:
+		entry.Outcome = OutcomeOK
+	case errors.Is(err, backend.ErrNotFound):
+		entry.Outcome = OutcomeNotFound
+	default:
+		entry.Outcome = OutcomeError
+		entry.Error = err.Error()
+	}
+
+	if werr := a.log.Append(entry); werr != nil && a.onErr != nil {
+		a.onErr(werr)
+	}
+}
+
+func (a *auditedBackend) GetPassword(account string) (string, error) {
+	value, err := a.backend.GetPassword(account)
+	a.record(Entry{Op: OpGet, Key: account}, err)
+	return value, err
+}
+
+func (a *auditedBackend) SetPassword(account, password string, update bool) error {
+	err := a.backend.SetPassword(account, password, update)
+	op := OpSet
+	if update {
+		op = OpUpdate
+	}
+	a.record(Entry{Op: op, Key: account}, err)
+	return err
+}
+
+func (a *auditedBackend) List() ([]string, error) {
+	accounts, err := a.backend.List()
+	a.record(Entry{Op: OpList}, err)
+	return accounts, err
+}
+
+func (a *auditedBackend) GetMultiplePasswords(accounts []string) (map[string]string, error) {
+	values, err := a.backend.GetMultiplePasswords(accounts)
+	a.record(Entry{Op: OpGetMultiple, Keys: accounts}, err)
+	return values, err
+}
+
+func (a *auditedBackend) DeletePassword(account string) error {
+	err := a.backend.DeletePassword(account)
+	a.record(Entry{Op: OpDelete, Key: account}, err)
+	return err
+}
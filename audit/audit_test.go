@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/backend/backendtest"
+)
+
+// failingListBackend is an in-memory backend whose List fails.
+type failingListBackend struct {
+	*backend.MemoryBackend
+}
+
+func (failingListBackend) List() ([]string, error) {
+	return nil, fmt.Errorf("list failed")
+}
+
+func TestWrapRecordsOperations(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log := Open(path)
+	ctx := Context{Command: "chainenv get", Cwd: "/work", PID: 10, PPID: 1, Parent: "zsh"}
+	b := Wrap(failingListBackend{backend.NewMemoryBackend(map[string]string{"TOKEN": "s3cret-value"})}, log, "keychain", ctx, func(err error) {
+		t.Errorf("audit write failed: %v", err)
+	})
+
+	b.GetPassword("TOKEN")
+	b.GetPassword("MISSING")
+	b.SetPassword("NEW", "another-s3cret", true)
+	b.List()
+	b.GetMultiplePasswords([]string{"A", "B"})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Fatalf("audit log contains a secret value:\n%s", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("expected 0600, got %v", perm)
+	}
+
+	entries, err := log.Read(Filter{})
+	if err != nil {
+		t.Fatalf("read entries: %v", err)
+	}
+	want := []struct{ op, outcome string }{
+		{OpGet, OutcomeOK},
+		{OpGet, OutcomeNotFound},
+		{OpUpdate, OutcomeOK},
+		{OpList, OutcomeError},
+		{OpGetMultiple, OutcomeOK},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(entries))
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Op != w.op || e.Outcome != w.outcome {
+			t.Errorf("entry %d: expected %s/%s, got %s/%s", i, w.op, w.outcome, e.Op, e.Outcome)
+		}
+		if e.Provider != "keychain" || e.Command != "chainenv get" || e.Cwd != "/work" || e.Parent != "zsh" {
+			t.Errorf("entry %d: unexpected context %#v", i, e)
+		}
+	}
+}
+
+func TestWrapConformance(t *testing.T) {
+	t.Parallel()
+
+	backendtest.Run(t, func(t *testing.T) backend.Backend {
+		log := Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+		return Wrap(backend.NewMemoryBackend(nil), log, "memory", Context{}, func(err error) {
+			t.Errorf("audit write failed: %v", err)
+		})
+	})
+}
+
+func TestReadFilter(t *testing.T) {
+	t.Parallel()
+
+	log := Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []Entry{
+		{Time: base, Op: OpGet, Key: "A"},
+		{Time: base.Add(time.Hour), Op: OpSet, Key: "A"},
+		{Time: base.Add(2 * time.Hour), Op: OpGetMultiple, Keys: []string{"A", "B"}},
+		{Time: base.Add(3 * time.Hour), Op: OpGet, Key: "B"},
+	} {
+		if err := log.Append(e); err != nil {
+			t.Fatalf("append %d: %v", i, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"all", Filter{}, 4},
+		{"key", Filter{Key: "B"}, 2},
+		{"op", Filter{Op: OpGet}, 2},
+		{"since", Filter{Since: base.Add(90 * time.Minute)}, 2},
+		{"until", Filter{Until: base.Add(time.Hour)}, 2},
+		{"combined", Filter{Key: "A", Op: OpGetMultiple}, 1},
+	}
+	for _, tt := range tests {
+		entries, err := log.Read(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: read: %v", tt.name, err)
+		}
+		if len(entries) != tt.want {
+			t.Errorf("%s: expected %d entries, got %d", tt.name, tt.want, len(entries))
+		}
+	}
+}
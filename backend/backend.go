@@ -2,6 +2,7 @@ package backend
 
 import (
 	"errors"
+	"time"
 
 	"github.com/dvcrn/chainenv/logger"
 )
@@ -14,6 +15,7 @@ type Backend interface {
 	SetPassword(account, password string, update bool) error
 	List() ([]string, error)
 	GetMultiplePasswords(accounts []string) (map[string]string, error)
+	DeletePassword(account string) error
 }
 
 // BackendOpts contains options for configuring a backend
@@ -41,3 +43,27 @@ func newBackendOpts(options ...BackendOption) *BackendOpts {
 	}
 	return opts
 }
+
+// ModTimeLister is implemented by backends that know when their secrets were
+// last modified.
+type ModTimeLister interface {
+	ModTimes() (map[string]time.Time, error)
+}
+
+// ModTimes returns the modification times of all secrets in b, looking
+// through backends that wrap others via an Unwrap method. ok is false if
+// neither b nor any backend it wraps provides modification times.
+func ModTimes(b Backend) (times map[string]time.Time, ok bool, err error) {
+	for b != nil {
+		if m, isLister := b.(ModTimeLister); isLister {
+			times, err := m.ModTimes()
+			return times, true, err
+		}
+		u, isWrapper := b.(interface{ Unwrap() Backend })
+		if !isWrapper {
+			break
+		}
+		b = u.Unwrap()
+	}
+	return nil, false, nil
+}
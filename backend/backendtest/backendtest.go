@@ -0,0 +1,341 @@
+// Package backendtest provides a conformance suite for backend.Backend
+// implementations.
+//
+// The suite pins down the behavior callers rely on:
+//
+//   - GetPassword and DeletePassword of a missing account return an error
+//     wrapping backend.ErrNotFound.
+//   - SetPassword without update stores a new account and fails, leaving
+//     the stored value alone, if the account already exists.
+//   - SetPassword with update replaces an existing value and creates the
+//     account if it is missing.
+//   - List returns every stored account exactly once.
+//   - GetMultiplePasswords returns the accounts it found and omits missing
+//     ones without failing.
+//   - Values round-trip unchanged, including unicode and multiline values.
+//   - All methods are safe for concurrent use.
+//
+// Run it from a provider's tests:
+//
+//	func TestConformance(t *testing.T) {
+//		backendtest.Run(t, func(t *testing.T) backend.Backend {
+//			return backend.NewMemoryBackend(nil)
+//		})
+//	}
+//
+// Accounts created by the suite are prefixed with a random per-test prefix
+// and deleted when the test finishes, so it can run against real keychains
+// and vaults that hold other items.
+package backendtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dvcrn/chainenv/backend"
+)
+
+// Factory returns the backend under test. It is called once per subtest.
+type Factory func(t *testing.T) backend.Backend
+
+// Values are the secrets every backend must store and return unchanged.
+var Values = map[string]string{
+	"ascii":     "s3cret-Value_123",
+	"unicode":   "pässwörd-パスワード-🔑",
+	"multiline": "-----BEGIN KEY-----\nline one\nline two\n-----END KEY-----",
+	"special":   `quote"s 'and' $HOME \back\slash =;&|`,
+}
+
+// concurrency is the number of goroutines used by the concurrency tests.
+const concurrency = 8
+
+// Run runs the conformance suite against the backends returned by
+// newBackend.
+func Run(t *testing.T, newBackend Factory) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s *suite)
+	}{
+		{"GetMissing", testGetMissing},
+		{"SetGet", testSetGet},
+		{"SetExisting", testSetExisting},
+		{"Update", testUpdate},
+		{"UpdateMissing", testUpdateMissing},
+		{"Delete", testDelete},
+		{"DeleteMissing", testDeleteMissing},
+		{"List", testList},
+		{"GetMultiple", testGetMultiple},
+		{"Values", testValues},
+		{"Concurrent", testConcurrent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newSuite(t, newBackend(t)))
+		})
+	}
+}
+
+// suite hands out account names unique to a test and removes them from the
+// backend when the test finishes.
+type suite struct {
+	b      backend.Backend
+	prefix string
+
+	mu       sync.Mutex
+	accounts []string
+}
+
+func newSuite(t *testing.T, b backend.Backend) *suite {
+	t.Helper()
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		t.Fatalf("generate prefix: %v", err)
+	}
+	s := &suite{b: b, prefix: "chainenv-test-" + hex.EncodeToString(buf) + "-"}
+	t.Cleanup(func() {
+		for _, account := range s.accounts {
+			if err := b.DeletePassword(account); err != nil && !errors.Is(err, backend.ErrNotFound) {
+				t.Logf("cleanup %s: %v", account, err)
+			}
+		}
+	})
+	return s
+}
+
+// account returns the account called name in this test.
+func (s *suite) account(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	account := s.prefix + name
+	if !slices.Contains(s.accounts, account) {
+		s.accounts = append(s.accounts, account)
+	}
+	return account
+}
+
+// set stores password for account and fails the test on error.
+func (s *suite) set(t *testing.T, account, password string) {
+	t.Helper()
+	if err := s.b.SetPassword(account, password, false); err != nil {
+		t.Fatalf("SetPassword(%q): %v", account, err)
+	}
+}
+
+// want fails the test unless account holds password.
+func (s *suite) want(t *testing.T, account, password string) {
+	t.Helper()
+	got, err := s.b.GetPassword(account)
+	if err != nil {
+		t.Fatalf("GetPassword(%q): %v", account, err)
+	}
+	if got != password {
+		t.Fatalf("GetPassword(%q) = %q, want %q", account, got, password)
+	}
+}
+
+// wantNotFound fails the test unless err wraps backend.ErrNotFound.
+func wantNotFound(t *testing.T, op string, err error) {
+	t.Helper()
+	if !errors.Is(err, backend.ErrNotFound) {
+		t.Fatalf("%s: got error %v, want one wrapping backend.ErrNotFound", op, err)
+	}
+}
+
+func testGetMissing(t *testing.T, s *suite) {
+	account := s.account("missing")
+	_, err := s.b.GetPassword(account)
+	wantNotFound(t, fmt.Sprintf("GetPassword(%q)", account), err)
+}
+
+func testSetGet(t *testing.T, s *suite) {
+	account := s.account("key")
+	s.set(t, account, "value")
+	s.want(t, account, "value")
+}
+
+func testSetExisting(t *testing.T, s *suite) {
+	account := s.account("key")
+	s.set(t, account, "first")
+	if err := s.b.SetPassword(account, "second", false); err == nil {
+		t.Fatalf("SetPassword(%q) of an existing account without update succeeded", account)
+	}
+	s.want(t, account, "first")
+}
+
+func testUpdate(t *testing.T, s *suite) {
+	account := s.account("key")
+	s.set(t, account, "first")
+	if err := s.b.SetPassword(account, "second", true); err != nil {
+		t.Fatalf("SetPassword(%q, update): %v", account, err)
+	}
+	s.want(t, account, "second")
+}
+
+func testUpdateMissing(t *testing.T, s *suite) {
+	account := s.account("key")
+	if err := s.b.SetPassword(account, "value", true); err != nil {
+		t.Fatalf("SetPassword(%q, update) of a missing account: %v", account, err)
+	}
+	s.want(t, account, "value")
+}
+
+func testDelete(t *testing.T, s *suite) {
+	account := s.account("key")
+	s.set(t, account, "value")
+	if err := s.b.DeletePassword(account); err != nil {
+		t.Fatalf("DeletePassword(%q): %v", account, err)
+	}
+	_, err := s.b.GetPassword(account)
+	wantNotFound(t, fmt.Sprintf("GetPassword(%q) after delete", account), err)
+
+	// The account can be created again after deleting it.
+	s.set(t, account, "again")
+	s.want(t, account, "again")
+}
+
+func testDeleteMissing(t *testing.T, s *suite) {
+	account := s.account("missing")
+	wantNotFound(t, fmt.Sprintf("DeletePassword(%q)", account), s.b.DeletePassword(account))
+}
+
+func testList(t *testing.T, s *suite) {
+	a, b, deleted := s.account("a"), s.account("b"), s.account("deleted")
+	s.set(t, a, "1")
+	s.set(t, b, "2")
+	s.set(t, deleted, "3")
+	if err := s.b.SetPassword(b, "updated", true); err != nil {
+		t.Fatalf("SetPassword(%q, update): %v", b, err)
+	}
+	if err := s.b.DeletePassword(deleted); err != nil {
+		t.Fatalf("DeletePassword(%q): %v", deleted, err)
+	}
+
+	accounts, err := s.b.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	// The backend may hold accounts that aren't ours.
+	var ours []string
+	for _, account := range accounts {
+		if strings.HasPrefix(account, s.prefix) {
+			ours = append(ours, account)
+		}
+	}
+	slices.Sort(ours)
+	if want := []string{a, b}; !slices.Equal(ours, want) {
+		t.Fatalf("List() = %q, want %q", ours, want)
+	}
+}
+
+func testGetMultiple(t *testing.T, s *suite) {
+	a, b, missing := s.account("a"), s.account("b"), s.account("missing")
+	s.set(t, a, "1")
+	s.set(t, b, "2")
+
+	values, err := s.b.GetMultiplePasswords([]string{a, missing, b})
+	if err != nil {
+		t.Fatalf("GetMultiplePasswords: %v", err)
+	}
+	want := map[string]string{a: "1", b: "2"}
+	if len(values) != len(want) || values[a] != want[a] || values[b] != want[b] {
+		t.Fatalf("GetMultiplePasswords() = %q, want %q", values, want)
+	}
+
+	values, err = s.b.GetMultiplePasswords(nil)
+	if err != nil {
+		t.Fatalf("GetMultiplePasswords(nil): %v", err)
+	}
+	if len(values) != 0 {
+		t.Fatalf("GetMultiplePasswords(nil) = %q, want no values", values)
+	}
+}
+
+func testValues(t *testing.T, s *suite) {
+	names := make([]string, 0, len(Values))
+	for name := range Values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var accounts []string
+	for _, name := range names {
+		account := s.account(name)
+		accounts = append(accounts, account)
+		s.set(t, account, Values[name])
+		s.want(t, account, Values[name])
+	}
+
+	values, err := s.b.GetMultiplePasswords(accounts)
+	if err != nil {
+		t.Fatalf("GetMultiplePasswords: %v", err)
+	}
+	for i, name := range names {
+		if got := values[accounts[i]]; got != Values[name] {
+			t.Errorf("GetMultiplePasswords()[%q] = %q, want %q", accounts[i], got, Values[name])
+		}
+	}
+}
+
+func testConcurrent(t *testing.T, s *suite) {
+	accounts := make([]string, concurrency)
+	for i := range accounts {
+		accounts[i] = s.account(fmt.Sprintf("concurrent-%d", i))
+	}
+
+	errs := make(chan error, 3*concurrency)
+	var wg sync.WaitGroup
+	for i, account := range accounts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value := fmt.Sprintf("value-%d", i)
+			if err := s.b.SetPassword(account, value, false); err != nil {
+				errs <- fmt.Errorf("SetPassword(%q): %w", account, err)
+				return
+			}
+			if err := s.b.SetPassword(account, value+"-updated", true); err != nil {
+				errs <- fmt.Errorf("SetPassword(%q, update): %w", account, err)
+				return
+			}
+			if got, err := s.b.GetPassword(account); err != nil || got != value+"-updated" {
+				errs <- fmt.Errorf("GetPassword(%q) = %q, %v", account, got, err)
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.b.GetMultiplePasswords(accounts); err != nil {
+				errs <- fmt.Errorf("GetMultiplePasswords: %w", err)
+			}
+			if _, err := s.b.List(); err != nil {
+				errs <- fmt.Errorf("List: %w", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if t.Failed() {
+		return
+	}
+
+	values, err := s.b.GetMultiplePasswords(accounts)
+	if err != nil {
+		t.Fatalf("GetMultiplePasswords: %v", err)
+	}
+	for i, account := range accounts {
+		if want := fmt.Sprintf("value-%d-updated", i); values[account] != want {
+			t.Errorf("GetMultiplePasswords()[%q] = %q, want %q", account, values[account], want)
+		}
+	}
+}
@@ -4,11 +4,14 @@ package backend
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/hex"
 	"fmt"
 	"os/exec"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 )
 
 type KeychainBackend struct{}
@@ -18,16 +21,54 @@ func NewKeychainBackend() (Backend, error) {
 }
 
 func (k *KeychainBackend) GetPassword(account string) (string, error) {
-	cmd := exec.Command("security", "find-generic-password", "-a", account, "-s", fmt.Sprintf("chainenv-%s", account), "-w")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		out := strings.TrimSpace(string(output))
+	// -w prints values that aren't printable ASCII, such as multiline or
+	// unicode ones, hex encoded without any marker, so read the password
+	// line -g writes to stderr instead.
+	cmd := exec.Command("security", "find-generic-password", "-a", account, "-s", fmt.Sprintf("chainenv-%s", account), "-g")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		out := strings.TrimSpace(stderr.String())
 		if strings.Contains(out, "could not be found") {
 			return "", fmt.Errorf("%w: %s", ErrNotFound, out)
 		}
 		return "", fmt.Errorf("error retrieving password: %v: %s", err, out)
 	}
-	return strings.TrimSpace(string(output)), nil
+	return parseKeychainPassword(stderr.String())
+}
+
+// parseKeychainPassword extracts the password from the output of
+// "security find-generic-password -g", which is either
+//
+//	password: "value"
+//
+// or, for values that aren't printable ASCII,
+//
+//	password: 0x76616C75650A  "value\012"
+func parseKeychainPassword(output string) (string, error) {
+	for _, line := range strings.Split(output, "\n") {
+		value, ok := strings.CutPrefix(line, "password:")
+		if !ok {
+			continue
+		}
+		value = strings.TrimPrefix(value, " ")
+		if value == "" {
+			return "", nil
+		}
+		if encoded, ok := strings.CutPrefix(value, "0x"); ok {
+			encoded, _, _ = strings.Cut(encoded, " ")
+			decoded, err := hex.DecodeString(encoded)
+			if err != nil {
+				return "", fmt.Errorf("error decoding password: %w", err)
+			}
+			return string(decoded), nil
+		}
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			return value[1 : len(value)-1], nil
+		}
+		return "", fmt.Errorf("unexpected password format in security output")
+	}
+	return "", fmt.Errorf("no password in security output")
 }
 
 func (k *KeychainBackend) SetPassword(account, password string, update bool) error {
@@ -43,6 +84,18 @@ func (k *KeychainBackend) SetPassword(account, password string, update bool) err
 	return nil
 }
 
+func (k *KeychainBackend) DeletePassword(account string) error {
+	cmd := exec.Command("security", "delete-generic-password", "-a", account, "-s", fmt.Sprintf("chainenv-%s", account))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		out := strings.TrimSpace(string(output))
+		if strings.Contains(out, "could not be found") {
+			return fmt.Errorf("%w: %s", ErrNotFound, out)
+		}
+		return fmt.Errorf("error deleting password: %v: %s", err, out)
+	}
+	return nil
+}
+
 var keychainServiceRegex = regexp.MustCompile(`"svce"<blob>="chainenv-(.+)"`)
 
 func (k *KeychainBackend) List() ([]string, error) {
@@ -80,6 +133,40 @@ func (k *KeychainBackend) List() ([]string, error) {
 	return accounts, nil
 }
 
+var keychainModTimeRegex = regexp.MustCompile(`"mdat"<timedate>=\S+\s+"(\d{14})Z`)
+
+// ModTimes returns the modification date of every chainenv item, taken from
+// the mdat attribute in the output of "security dump-keychain".
+func (k *KeychainBackend) ModTimes() (map[string]time.Time, error) {
+	out, err := exec.Command("security", "dump-keychain").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error listing keychain items: %v", err)
+	}
+
+	// Attributes of an item are listed between its "keychain:" header and
+	// the next one, with mdat before svce.
+	times := make(map[string]time.Time)
+	var modified time.Time
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "keychain:") {
+			modified = time.Time{}
+			continue
+		}
+		if matches := keychainModTimeRegex.FindStringSubmatch(line); len(matches) > 1 {
+			if t, err := time.Parse("20060102150405", matches[1]); err == nil {
+				modified = t
+			}
+			continue
+		}
+		if matches := keychainServiceRegex.FindStringSubmatch(line); len(matches) > 1 && !modified.IsZero() {
+			times[matches[1]] = modified
+		}
+	}
+	return times, scanner.Err()
+}
+
 func (k *KeychainBackend) GetMultiplePasswords(accounts []string) (map[string]string, error) {
 	results := make(map[string]string)
 	resultsChan := make(chan struct {
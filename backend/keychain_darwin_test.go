@@ -0,0 +1,35 @@
+//go:build darwin
+
+package backend
+
+import "testing"
+
+func TestParseKeychainPassword(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"password: \"s3cret\"\n", "s3cret"},
+		{"password: \"quote\"s\"\n", `quote"s`},
+		{"password: \n", ""},
+		{"password:\n", ""},
+		{"password: 0x6C696E65206F6E650A6C696E652074776F  \"line one\\012line two\"\n", "line one\nline two"},
+		{"password: 0x70C3A4737377C3B67264  \"p\\303\\244ssw\\303\\266rd\"\n", "pässwörd"},
+	}
+	for _, tt := range tests {
+		got, err := parseKeychainPassword(tt.output)
+		if err != nil {
+			t.Errorf("parseKeychainPassword(%q): %v", tt.output, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseKeychainPassword(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+
+	if _, err := parseKeychainPassword("keychain: \"login.keychain-db\"\n"); err == nil {
+		t.Errorf("expected error without password line")
+	}
+}
@@ -60,6 +60,17 @@ func (k *KeychainBackend) SetPassword(account, password string, update bool) err
 	return nil
 }
 
+func (k *KeychainBackend) DeletePassword(account string) error {
+	// Secret Service silently ignores removal of missing items, so check first.
+	if _, err := k.GetPassword(account); err != nil {
+		return err
+	}
+	if err := k.ring.Remove(account); err != nil {
+		return fmt.Errorf("error deleting password: %w", err)
+	}
+	return nil
+}
+
 func (k *KeychainBackend) List() ([]string, error) {
 	keys, err := k.ring.Keys()
 	if err != nil {
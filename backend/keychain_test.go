@@ -0,0 +1,29 @@
+//go:build darwin || linux
+
+package backend_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/backend/backendtest"
+)
+
+// keychainTestEnv enables the keychain conformance tests, which read and
+// write the user's real keychain.
+const keychainTestEnv = "CHAINENV_TEST_KEYCHAIN"
+
+func TestKeychainBackendConformance(t *testing.T) {
+	if os.Getenv(keychainTestEnv) == "" {
+		t.Skipf("set %s=1 to run against the system keychain", keychainTestEnv)
+	}
+
+	backendtest.Run(t, func(t *testing.T) backend.Backend {
+		b, err := backend.NewKeychainBackend()
+		if err != nil {
+			t.Fatalf("NewKeychainBackend: %v", err)
+		}
+		return b
+	})
+}
@@ -0,0 +1,75 @@
+package backend
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MemoryBackend keeps passwords in memory. It is meant for tests and for
+// embedding chainenv where nothing should be persisted.
+type MemoryBackend struct {
+	mu        sync.RWMutex
+	passwords map[string]string
+}
+
+// NewMemoryBackend returns a backend holding a copy of passwords, which may
+// be nil.
+func NewMemoryBackend(passwords map[string]string) *MemoryBackend {
+	m := &MemoryBackend{passwords: make(map[string]string, len(passwords))}
+	for account, password := range passwords {
+		m.passwords[account] = password
+	}
+	return m
+}
+
+func (m *MemoryBackend) GetPassword(account string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	password, ok := m.passwords[account]
+	if !ok {
+		return "", fmt.Errorf("%w: the item '%s' does not exist", ErrNotFound, account)
+	}
+	return password, nil
+}
+
+func (m *MemoryBackend) SetPassword(account, password string, update bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.passwords[account]; exists && !update {
+		return fmt.Errorf("item '%s' already exists", account)
+	}
+	m.passwords[account] = password
+	return nil
+}
+
+func (m *MemoryBackend) DeletePassword(account string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.passwords[account]; !ok {
+		return fmt.Errorf("%w: the item '%s' does not exist", ErrNotFound, account)
+	}
+	delete(m.passwords, account)
+	return nil
+}
+
+func (m *MemoryBackend) List() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	accounts := make([]string, 0, len(m.passwords))
+	for account := range m.passwords {
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+func (m *MemoryBackend) GetMultiplePasswords(accounts []string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	results := make(map[string]string)
+	for _, account := range accounts {
+		if password, ok := m.passwords[account]; ok {
+			results[account] = password
+		}
+	}
+	return results, nil
+}
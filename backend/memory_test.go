@@ -0,0 +1,54 @@
+package backend_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/backend/backendtest"
+)
+
+func TestMemoryBackendConformance(t *testing.T) {
+	t.Parallel()
+
+	backendtest.Run(t, func(t *testing.T) backend.Backend {
+		return backend.NewMemoryBackend(nil)
+	})
+}
+
+func TestNewMemoryBackendCopiesPasswords(t *testing.T) {
+	t.Parallel()
+
+	passwords := map[string]string{"A": "1"}
+	b := backend.NewMemoryBackend(passwords)
+	passwords["A"] = "changed"
+
+	if v, err := b.GetPassword("A"); err != nil || v != "1" {
+		t.Fatalf("GetPassword(A) = %q, %v, want 1", v, err)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	t.Parallel()
+
+	var seen []string
+	b := backend.Observe(backend.NewMemoryBackend(map[string]string{"A": "1", "B": "2"}), func(values ...string) {
+		seen = append(seen, values...)
+	})
+
+	if _, err := b.GetPassword("A"); err != nil {
+		t.Fatalf("GetPassword: %v", err)
+	}
+	if err := b.SetPassword("C", "3", false); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	if _, err := b.GetMultiplePasswords([]string{"B", "MISSING"}); err != nil {
+		t.Fatalf("GetMultiplePasswords: %v", err)
+	}
+	if _, err := b.GetPassword("MISSING"); err == nil {
+		t.Fatal("GetPassword(MISSING) succeeded")
+	}
+	if want := []string{"1", "3", "2"}; !slices.Equal(seen, want) {
+		t.Errorf("observed %q, want %q", seen, want)
+	}
+}
@@ -0,0 +1,49 @@
+package backend
+
+// observedBackend reports every secret value passing through the wrapped
+// backend.
+type observedBackend struct {
+	backend Backend
+	observe func(values ...string)
+}
+
+// Observe returns a backend passing every secret value read from or written
+// to b to observe, before it is returned or stored. It is used to tell the
+// logger which values to redact.
+func Observe(b Backend, observe func(values ...string)) Backend {
+	return &observedBackend{backend: b, observe: observe}
+}
+
+// Unwrap returns the wrapped backend.
+func (o *observedBackend) Unwrap() Backend {
+	return o.backend
+}
+
+func (o *observedBackend) GetPassword(account string) (string, error) {
+	value, err := o.backend.GetPassword(account)
+	if err == nil {
+		o.observe(value)
+	}
+	return value, err
+}
+
+func (o *observedBackend) SetPassword(account, password string, update bool) error {
+	o.observe(password)
+	return o.backend.SetPassword(account, password, update)
+}
+
+func (o *observedBackend) List() ([]string, error) {
+	return o.backend.List()
+}
+
+func (o *observedBackend) GetMultiplePasswords(accounts []string) (map[string]string, error) {
+	values, err := o.backend.GetMultiplePasswords(accounts)
+	for _, v := range values {
+		o.observe(v)
+	}
+	return values, err
+}
+
+func (o *observedBackend) DeletePassword(account string) error {
+	return o.backend.DeletePassword(account)
+}
@@ -1,10 +1,14 @@
 package backend
 
 import (
+	"errors"
 	"fmt"
 	"maps"
+	"os/exec"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dvcrn/chainenv/logger"
 	"github.com/dvcrn/go-1password-cli/op"
@@ -12,9 +16,12 @@ import (
 
 type OnePasswordBackend struct {
 	client    *op.Client
-	vault     *op.Vault
 	vaultName string
 
+	// mu guards vault, so concurrent first calls don't each create it.
+	mu    sync.Mutex
+	vault *op.Vault
+
 	logger *logger.Logger
 }
 
@@ -30,6 +37,14 @@ func NewOnePasswordBackend(vaultName string, opts ...BackendOption) *OnePassword
 }
 
 func (o *OnePasswordBackend) ensureVaultExists() error {
+	// The vault is looked up once per backend, which matters for long-lived
+	// backends such as the ones held by the agent.
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.vault != nil {
+		return nil
+	}
+
 	vaults, err := o.client.Vaults()
 	if err != nil {
 		o.logger.Err("couldn't get vaults: %s", err.Error())
@@ -40,7 +55,7 @@ func (o *OnePasswordBackend) ensureVaultExists() error {
 	for _, v := range vaults {
 		if v.Name == o.vaultName {
 			vault = v
-			o.logger.Debug("Using existing 1Password vault: ID: %s, Name: %s, ContentVersion: %d\n", vault.ID, vault.Name, vault.ContentVersion)
+			o.logger.Debug("Using existing 1Password vault: ID: %s, Name: %s, ContentVersion: %d", vault.ID, vault.Name, vault.ContentVersion)
 			break
 		}
 	}
@@ -57,7 +72,7 @@ func (o *OnePasswordBackend) ensureVaultExists() error {
 			return err
 		}
 
-		o.logger.Info("Created new 1Password vault: ID: %s, Name: %s\n", vault.ID, vault.Name)
+		o.logger.Info("Created new 1Password vault: ID: %s, Name: %s", vault.ID, vault.Name)
 	}
 
 	o.vault = vault
@@ -88,21 +103,16 @@ func (o *OnePasswordBackend) SetPassword(account, password string, update bool)
 
 	vaultItem, _ := o.client.VaultItem(account, o.vault.ID)
 
-	// If updating, first check if the item exists
-	if update {
+	// Updating a missing item creates it, like the keychain backends do.
+	if update && vaultItem != nil {
 		o.logger.Debug("Running in update mode")
 
-		if vaultItem == nil {
-			return fmt.Errorf("item not found for update")
-		}
-
-		// If the item exists, update it
 		editedItem, err := o.client.EditItemField(o.vault.ID, vaultItem.ID, op.Assignment{Name: "password", Value: password})
 		if err != nil {
 			return fmt.Errorf("error updating item in 1Password: %v", err)
 		}
 
-		o.logger.Debug("Updated item: %s, value: %s\n", editedItem.Title, password)
+		o.logger.Debug("Updated item: %s", editedItem.Title)
 
 		return nil
 	}
@@ -122,7 +132,27 @@ func (o *OnePasswordBackend) SetPassword(account, password string, update bool)
 		return fmt.Errorf("error creating item in 1Password: %v", err)
 	}
 
-	o.logger.Debug("Created item: %s\n", item.Title)
+	o.logger.Debug("Created item: %s", item.Title)
+
+	return nil
+}
+
+func (o *OnePasswordBackend) DeletePassword(account string) error {
+	if err := o.ensureVaultExists(); err != nil {
+		return fmt.Errorf("error ensuring vault exists: %v", err)
+	}
+
+	// go-1password-cli has no delete support, so call op directly.
+	cmd := exec.Command("op", "item", "delete", account, "--vault", o.vault.ID)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		out := strings.TrimSpace(string(output))
+		if strings.Contains(out, "isn't an item") {
+			return fmt.Errorf("%w: the item '%s' does not exist in the vault", ErrNotFound, account)
+		}
+		return fmt.Errorf("error deleting item in 1Password: %v: %s", err, out)
+	}
+
+	o.logger.Debug("Deleted item: %s", account)
 
 	return nil
 }
@@ -145,6 +175,23 @@ func (o *OnePasswordBackend) List() ([]string, error) {
 	return accounts, nil
 }
 
+func (o *OnePasswordBackend) ModTimes() (map[string]time.Time, error) {
+	if err := o.ensureVaultExists(); err != nil {
+		return nil, fmt.Errorf("error ensuring vault exists: %v", err)
+	}
+
+	items, err := o.client.ItemsByVault(o.vault.ID, op.WithTags([]string{"chainenv"}))
+	if err != nil {
+		return nil, fmt.Errorf("error listing items in 1Password: %v", err)
+	}
+
+	times := make(map[string]time.Time)
+	for _, item := range items {
+		times[item.Title] = item.UpdatedAt
+	}
+	return times, nil
+}
+
 func (o *OnePasswordBackend) GetMultiplePasswords(accounts []string) (map[string]string, error) {
 	if err := o.ensureVaultExists(); err != nil {
 		return nil, fmt.Errorf("error ensuring vault exists: %v", err)
@@ -158,7 +205,10 @@ func (o *OnePasswordBackend) GetMultiplePasswords(accounts []string) (map[string
 	vals := slices.Collect(maps.Keys(refs))
 	items, err := o.client.ReadMulti(vals)
 	if err != nil {
-		return nil, fmt.Errorf("err reading item refs: %v", err)
+		// op inject fails as a whole if a single item is missing, so read
+		// the items one by one to tell missing items from other errors.
+		o.logger.Debug("Batch read failed, reading items one by one: %v", err)
+		return o.getPasswordsOneByOne(accounts)
 	}
 
 	// parse the refs back to their value
@@ -169,3 +219,20 @@ func (o *OnePasswordBackend) GetMultiplePasswords(accounts []string) (map[string
 
 	return results, nil
 }
+
+// getPasswordsOneByOne reads every account with GetPassword, leaving out the
+// ones that don't exist.
+func (o *OnePasswordBackend) getPasswordsOneByOne(accounts []string) (map[string]string, error) {
+	results := make(map[string]string)
+	for _, account := range accounts {
+		value, err := o.GetPassword(account)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results[account] = value
+	}
+	return results, nil
+}
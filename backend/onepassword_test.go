@@ -0,0 +1,202 @@
+package backend_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/backend/backendtest"
+	"github.com/dvcrn/chainenv/internal/fakeop"
+)
+
+// opTestVaultEnv names a 1Password vault to run the conformance tests
+// against using the real op CLI instead of the fake.
+const opTestVaultEnv = "CHAINENV_TEST_OP_VAULT"
+
+func TestOnePasswordBackendConformance(t *testing.T) {
+	if vault := os.Getenv(opTestVaultEnv); vault != "" {
+		backendtest.Run(t, func(t *testing.T) backend.Backend {
+			return backend.NewOnePasswordBackend(vault)
+		})
+		return
+	}
+
+	f := fakeop.Start(t)
+	// Let concurrent op processes overlap like the real, slow CLI does.
+	f.SetLatency(5 * time.Millisecond)
+	vaults := 0
+	backendtest.Run(t, func(t *testing.T) backend.Backend {
+		vaults++
+		return backend.NewOnePasswordBackend(fmt.Sprintf("conformance-%d", vaults))
+	})
+}
+
+func TestOnePasswordCreatesVault(t *testing.T) {
+	f := fakeop.Start(t)
+	b := backend.NewOnePasswordBackend("chainenv")
+
+	if err := b.SetPassword("API_TOKEN", "token", false); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	if _, err := b.GetPassword("API_TOKEN"); err != nil {
+		t.Fatalf("GetPassword: %v", err)
+	}
+
+	vaults := f.State().Vaults
+	if len(vaults) != 1 || vaults[0].Name != "chainenv" || vaults[0].Description != "Created by chainenv" {
+		t.Fatalf("vaults = %+v, want a single chainenv vault created by chainenv", vaults)
+	}
+	// The vault is looked up once per backend.
+	if n := f.CountCalls("vault list"); n != 1 {
+		t.Errorf("vault list ran %d times, want 1", n)
+	}
+	if n := f.CountCalls("vault create"); n != 1 {
+		t.Errorf("vault create ran %d times, want 1", n)
+	}
+}
+
+func TestOnePasswordUsesExistingVault(t *testing.T) {
+	f := fakeop.Start(t)
+	f.AddVault("Private")
+	id := f.AddVault("chainenv")
+	f.AddItem(id, "API_TOKEN", "token", "chainenv")
+
+	v, err := backend.NewOnePasswordBackend("chainenv").GetPassword("API_TOKEN")
+	if err != nil || v != "token" {
+		t.Fatalf("GetPassword = %q, %v, want token", v, err)
+	}
+	if n := f.CountCalls("vault create"); n != 0 {
+		t.Errorf("vault create ran %d times, want 0", n)
+	}
+}
+
+func TestOnePasswordUpdateMissingItem(t *testing.T) {
+	f := fakeop.Start(t)
+	b := backend.NewOnePasswordBackend("chainenv")
+
+	if err := b.SetPassword("API_TOKEN", "token", true); err != nil {
+		t.Fatalf("SetPassword(update) of a missing item: %v", err)
+	}
+	if n := f.CountCalls("item edit"); n != 0 {
+		t.Errorf("item edit ran %d times, want 0", n)
+	}
+	items := f.State().Items
+	if len(items) != 1 || items[0].Title != "API_TOKEN" || !slices.Contains(items[0].Tags, "chainenv") {
+		t.Fatalf("items = %+v, want API_TOKEN tagged chainenv", items)
+	}
+
+	if err := b.SetPassword("API_TOKEN", "rotated", true); err != nil {
+		t.Fatalf("SetPassword(update): %v", err)
+	}
+	if n := f.CountCalls("item edit"); n != 1 {
+		t.Errorf("item edit ran %d times, want 1", n)
+	}
+	if v, err := b.GetPassword("API_TOKEN"); err != nil || v != "rotated" {
+		t.Fatalf("GetPassword = %q, %v, want rotated", v, err)
+	}
+}
+
+func TestOnePasswordListFiltersByTag(t *testing.T) {
+	f := fakeop.Start(t)
+	id := f.AddVault("chainenv")
+	f.AddItem(id, "API_TOKEN", "token", "chainenv")
+	f.AddItem(id, "DB_PASSWORD", "hunter2", "prod", "chainenv")
+	f.AddItem(id, "Wi-Fi", "not ours")
+	other := f.AddVault("Private")
+	f.AddItem(other, "ELSEWHERE", "x", "chainenv")
+
+	b := backend.NewOnePasswordBackend("chainenv")
+	accounts, err := b.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	slices.Sort(accounts)
+	if want := []string{"API_TOKEN", "DB_PASSWORD"}; !slices.Equal(accounts, want) {
+		t.Errorf("List = %q, want %q", accounts, want)
+	}
+
+	times, ok, err := backend.ModTimes(b)
+	if err != nil || !ok {
+		t.Fatalf("ModTimes = %v, %v", ok, err)
+	}
+	if len(times) != 2 || times["API_TOKEN"].IsZero() {
+		t.Errorf("ModTimes = %v, want times for the two tagged items", times)
+	}
+}
+
+func TestOnePasswordGetMultiplePasswords(t *testing.T) {
+	f := fakeop.Start(t)
+	id := f.AddVault("chainenv")
+	f.AddItem(id, "A", "1", "chainenv")
+	f.AddItem(id, "B", "2", "chainenv")
+	f.AddItem(id, "C", "3", "chainenv")
+	b := backend.NewOnePasswordBackend("chainenv")
+
+	values, err := b.GetMultiplePasswords([]string{"C", "A", "B"})
+	if err != nil {
+		t.Fatalf("GetMultiplePasswords: %v", err)
+	}
+	if len(values) != 3 || values["A"] != "1" || values["B"] != "2" || values["C"] != "3" {
+		t.Fatalf("GetMultiplePasswords = %v", values)
+	}
+	// All values are read with a single op inject.
+	if n, reads := f.CountCalls("inject"), f.CountCalls("read"); n != 1 || reads != 0 {
+		t.Errorf("inject ran %d times and read %d times, want a single inject", n, reads)
+	}
+}
+
+func TestOnePasswordGetMultiplePasswordsFallback(t *testing.T) {
+	f := fakeop.Start(t)
+	id := f.AddVault("chainenv")
+	f.AddItem(id, "A", "1", "chainenv")
+	// Multiline values break the JSON template op inject fills in.
+	f.AddItem(id, "KEY", "line one\nline two", "chainenv")
+	b := backend.NewOnePasswordBackend("chainenv")
+
+	values, err := b.GetMultiplePasswords([]string{"A", "KEY", "MISSING"})
+	if err != nil {
+		t.Fatalf("GetMultiplePasswords: %v", err)
+	}
+	if len(values) != 2 || values["A"] != "1" || values["KEY"] != "line one\nline two" {
+		t.Fatalf("GetMultiplePasswords = %q", values)
+	}
+
+	f.Fail("inject", 1, "connection reset")
+	f.Fail("read", 0, "connection reset")
+	if _, err := b.GetMultiplePasswords([]string{"A"}); err == nil || errors.Is(err, backend.ErrNotFound) {
+		t.Fatalf("GetMultiplePasswords with failing op = %v, want an error other than ErrNotFound", err)
+	}
+}
+
+func TestOnePasswordErrors(t *testing.T) {
+	f := fakeop.Start(t)
+	id := f.AddVault("chainenv")
+	f.AddItem(id, "A", "1", "chainenv")
+
+	f.Fail("vault list", 1, "You are not currently signed in.")
+	b := backend.NewOnePasswordBackend("chainenv")
+	if _, err := b.GetPassword("A"); err == nil {
+		t.Fatal("GetPassword succeeded without being signed in")
+	}
+	// The failed vault lookup isn't cached.
+	if v, err := b.GetPassword("A"); err != nil || v != "1" {
+		t.Fatalf("GetPassword = %q, %v, want 1", v, err)
+	}
+
+	f.Fail("read", 1, "connection reset")
+	if _, err := b.GetPassword("A"); err == nil || errors.Is(err, backend.ErrNotFound) {
+		t.Fatalf("GetPassword with failing op = %v, want an error other than ErrNotFound", err)
+	}
+
+	f.Fail("item edit", 1, "connection reset")
+	if err := b.SetPassword("A", "2", true); err == nil {
+		t.Fatal("SetPassword succeeded although op item edit failed")
+	}
+	if v, _ := b.GetPassword("A"); v != "1" {
+		t.Fatalf("value after failed update = %q, want 1", v)
+	}
+}
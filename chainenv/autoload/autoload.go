@@ -0,0 +1,24 @@
+// Package autoload sets the keys declared in the chainenv config of the
+// working directory as environment variables when imported:
+//
+//	import _ "github.com/dvcrn/chainenv/chainenv/autoload"
+//
+// Variables that are already set are kept. Nothing happens if there is no
+// config; other failures are reported on stderr.
+package autoload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dvcrn/chainenv/chainenv"
+)
+
+func init() {
+	err := chainenv.LoadEnv(context.Background(), chainenv.Options{})
+	if err != nil && !errors.Is(err, chainenv.ErrNoConfig) {
+		fmt.Fprintf(os.Stderr, "chainenv: %v\n", err)
+	}
+}
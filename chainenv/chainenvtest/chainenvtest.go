@@ -0,0 +1,64 @@
+// Package chainenvtest provides helpers for tests that need secrets managed
+// by chainenv, skipping them where the secrets aren't available:
+//
+//	func TestIntegration(t *testing.T) {
+//		chainenvtest.Setenv(t, "API_TOKEN")
+//		...
+//	}
+package chainenvtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/chainenv"
+)
+
+// Load resolves keys with chainenv.Load and returns their values. The test is
+// skipped if a key is missing or its backend isn't available, and fails on
+// any other error.
+func Load(t testing.TB, keys ...string) map[string]string {
+	t.Helper()
+	return LoadWith(t, chainenv.Options{Keys: keys})
+}
+
+// LoadWith is like Load with explicit options.
+func LoadWith(t testing.TB, opts chainenv.Options) map[string]string {
+	t.Helper()
+
+	values, err := chainenv.Load(context.Background(), opts)
+	if err == nil {
+		return values
+	}
+	if unavailable(err) {
+		t.Skipf("chainenv secrets unavailable: %v", err)
+	}
+	t.Fatalf("chainenv: %v", err)
+	return nil
+}
+
+// Setenv loads keys like Load and sets them as environment variables for the
+// duration of the test with t.Setenv, so it can't be used in parallel tests.
+func Setenv(t testing.TB, keys ...string) {
+	t.Helper()
+	for key, value := range Load(t, keys...) {
+		t.Setenv(key, value)
+	}
+}
+
+// unavailable reports whether every error in err is a missing secret or
+// config, or a backend that couldn't be initialized.
+func unavailable(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if !unavailable(e) {
+				return false
+			}
+		}
+		return true
+	}
+	var backendErr *chainenv.BackendError
+	return errors.Is(err, backend.ErrNotFound) || errors.Is(err, chainenv.ErrNoConfig) || errors.As(err, &backendErr)
+}
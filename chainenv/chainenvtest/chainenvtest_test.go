@@ -0,0 +1,35 @@
+package chainenvtest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/chainenv"
+)
+
+func TestUnavailable(t *testing.T) {
+	t.Parallel()
+
+	missing := fmt.Errorf("API_TOKEN: %w", backend.ErrNotFound)
+	noBackend := fmt.Errorf("DB_PASSWORD: %w", &chainenv.BackendError{Provider: "1password", Err: errors.New("op not found")})
+	broken := errors.New("LOG_LEVEL: permission denied")
+
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{missing, true},
+		{noBackend, true},
+		{chainenv.ErrNoConfig, true},
+		{errors.Join(missing, noBackend), true},
+		{errors.Join(missing, broken), false},
+		{broken, false},
+	}
+	for _, tt := range tests {
+		if got := unavailable(tt.err); got != tt.want {
+			t.Errorf("unavailable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package chainenv
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dvcrn/chainenv/agent"
+	"github.com/dvcrn/chainenv/audit"
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/config"
+	"github.com/dvcrn/chainenv/logger"
+)
+
+// ConnectOptions configures Connect. The zero value connects like the
+// chainenv command does.
+type ConnectOptions struct {
+	// Command names the caller in the audit log.
+	Command string
+	// Logger receives debug messages and audit log write failures, and
+	// redacts every secret value passing through the backend. Defaults to a
+	// text logger writing to stderr.
+	Logger *logger.Logger
+	// NewBackend initializes the backend in this process when no agent is
+	// used. Defaults to NewBackend.
+	NewBackend func(provider, vault string, cfg *config.Config) (backend.Backend, error)
+}
+
+// Connect returns the backend for provider: through the agent if
+// CHAINENV_AGENT_SOCK points at a running one, initialized in this process
+// otherwise, and recording every operation if CHAINENV_AUDIT_LOG is set. If
+// the agent can't be reached and the backend can't be initialized either, the
+// error reports both.
+func Connect(provider, vault string, cfg *config.Config, opts ConnectOptions) (backend.Backend, error) {
+	log := opts.Logger
+	if log == nil {
+		log = logger.NewLogger(false)
+	}
+	newBackend := opts.NewBackend
+	if newBackend == nil {
+		newBackend = func(provider, vault string, cfg *config.Config) (backend.Backend, error) {
+			return NewBackend(provider, vault, cfg, backend.WithLogger(log))
+		}
+	}
+
+	var b backend.Backend
+	var agentErr error
+	if socket := os.Getenv(agent.SockEnv); socket != "" {
+		client, err := agent.NewClient(socket, provider, vault, OpServiceAccountTokenKey(cfg))
+		if err == nil {
+			log.Debug("Using agent at %s for %s", socket, provider)
+			b = client
+		} else {
+			log.Debug("Agent unavailable, using %s directly: %v", provider, err)
+			agentErr = err
+		}
+	}
+	if b == nil {
+		var err error
+		if b, err = newBackend(provider, vault, cfg); err != nil {
+			if agentErr != nil {
+				return nil, fmt.Errorf("%w; %w", agentErr, err)
+			}
+			return nil, err
+		}
+	}
+	b = backend.Observe(b, log.AddSecrets)
+
+	if auditLog := audit.FromEnv(); auditLog != nil {
+		b = audit.Wrap(b, auditLog, provider, audit.CurrentContext(opts.Command), func(err error) {
+			log.Err("Failed to write audit log: %v", err)
+		})
+	}
+	return b, nil
+}
@@ -0,0 +1,93 @@
+package chainenv
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvcrn/chainenv/agent"
+	"github.com/dvcrn/chainenv/audit"
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/config"
+)
+
+var errNoKeyring = errors.New("no keyring available")
+
+func failingBackend(provider, vault string, cfg *config.Config) (backend.Backend, error) {
+	return nil, errNoKeyring
+}
+
+func TestConnectUsesAgent(t *testing.T) {
+	t.Setenv(audit.PathEnv, "")
+	socket := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	l, err := agent.Listen(socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	served := backend.NewMemoryBackend(map[string]string{"API_TOKEN": "from-agent"})
+	go agent.NewServer(func(provider, vault, tokenKey string) (backend.Backend, error) {
+		return served, nil
+	}, time.Minute, 0).Serve(l)
+	t.Setenv(agent.SockEnv, socket)
+
+	b, err := Connect("keychain", DefaultVault, nil, ConnectOptions{NewBackend: failingBackend})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if v, err := b.GetPassword("API_TOKEN"); err != nil || v != "from-agent" {
+		t.Fatalf("get: %q, %v", v, err)
+	}
+}
+
+func TestConnectFallsBackWithoutAgent(t *testing.T) {
+	t.Setenv(audit.PathEnv, "")
+	t.Setenv(agent.SockEnv, filepath.Join(t.TempDir(), "missing.sock"))
+
+	direct := backend.NewMemoryBackend(map[string]string{"API_TOKEN": "direct"})
+	b, err := Connect("keychain", DefaultVault, nil, ConnectOptions{
+		NewBackend: func(provider, vault string, cfg *config.Config) (backend.Backend, error) {
+			return direct, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if v, err := b.GetPassword("API_TOKEN"); err != nil || v != "direct" {
+		t.Fatalf("get: %q, %v", v, err)
+	}
+
+	_, err = Connect("keychain", DefaultVault, nil, ConnectOptions{NewBackend: failingBackend})
+	if !errors.Is(err, errNoKeyring) || !strings.Contains(err.Error(), "connect to agent") {
+		t.Fatalf("expected both the agent and the backend error, got %v", err)
+	}
+}
+
+func TestConnectAudits(t *testing.T) {
+	t.Setenv(agent.SockEnv, "")
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	t.Setenv(audit.PathEnv, path)
+
+	b, err := Connect("keychain", DefaultVault, nil, ConnectOptions{
+		Command: "test",
+		NewBackend: func(provider, vault string, cfg *config.Config) (backend.Backend, error) {
+			return backend.NewMemoryBackend(map[string]string{"API_TOKEN": "tok"}), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if _, err := b.GetPassword("API_TOKEN"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	entries, err := audit.Open(path).Read(audit.Filter{})
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "API_TOKEN" || entries[0].Op != audit.OpGet {
+		t.Fatalf("unexpected audit entries %+v", entries)
+	}
+}
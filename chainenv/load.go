@@ -0,0 +1,130 @@
+// Package chainenv loads the secrets declared in a project's chainenv config
+// from their backends, for Go programs and tests that would otherwise shell
+// out to the chainenv command.
+//
+//	values, err := chainenv.Load(ctx, chainenv.Options{})
+//
+// Keys are resolved the same way "chainenv get-env" resolves them: through
+// the provider configured for each key, falling back to its default.
+package chainenv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/config"
+)
+
+// ErrNoConfig is returned by Load when no keys were requested and no config
+// was found.
+var ErrNoConfig = errors.New("no chainenv config found")
+
+// Options configures Load and LoadEnv. The zero value loads every key declared
+// in the config found in the working directory.
+type Options struct {
+	// Dir is searched for .chainenv.toml or chainenv.toml. Defaults to the
+	// working directory.
+	Dir string
+	// ConfigPath loads this config instead of searching Dir.
+	ConfigPath string
+	// Keys to load. Defaults to every key declared in the config.
+	Keys []string
+	// Provider is used for keys without a configured provider. Defaults to
+	// DefaultProvider.
+	Provider string
+	// Vault is the 1Password vault. Defaults to DefaultVault.
+	Vault string
+	// NewBackend initializes backends. Defaults to Connect, so the agent and
+	// audit log are used when configured through the environment.
+	NewBackend BackendFunc
+	// Override makes LoadEnv replace variables that are already set.
+	Override bool
+}
+
+// Load resolves the keys selected by opts. Keys configured with file = true
+// are returned as values; no files are written. If any key can't be resolved,
+// the error lists every failing key.
+func Load(ctx context.Context, opts Options) (map[string]string, error) {
+	cfg, err := opts.config()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := opts.Keys
+	if len(keys) == 0 {
+		if cfg == nil {
+			return nil, ErrNoConfig
+		}
+		keys = KeyNames(cfg)
+	}
+
+	provider := opts.Provider
+	if provider == "" {
+		provider = DefaultProvider
+	}
+	vault := opts.Vault
+	if vault == "" {
+		vault = DefaultVault
+	}
+	newBackend := opts.NewBackend
+	if newBackend == nil {
+		command := filepath.Base(os.Args[0])
+		newBackend = func(provider string) (backend.Backend, error) {
+			return Connect(provider, vault, cfg, ConnectOptions{Command: command})
+		}
+	}
+
+	r := NewResolver(cfg, provider, newBackend)
+	values := make(map[string]string, len(keys))
+	var errs []error
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		value, _, err := r.Resolve(key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		values[key] = value
+	}
+	return values, errors.Join(errs...)
+}
+
+// LoadEnv resolves the keys selected by opts like Load and sets them as
+// environment variables of the current process. Variables that are already
+// set are kept unless opts.Override is true. Nothing is set if any key fails.
+func LoadEnv(ctx context.Context, opts Options) error {
+	values, err := Load(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); set && !opts.Override {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("set %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// config loads the config selected by o, or nil if there is none.
+func (o Options) config() (*config.Config, error) {
+	if o.ConfigPath != "" {
+		return config.Load(o.ConfigPath)
+	}
+	dir := o.Dir
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+	}
+	return FindConfig(dir)
+}
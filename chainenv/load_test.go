@@ -0,0 +1,153 @@
+package chainenv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/ref"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".chainenv.toml"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// fakeBackends returns in-memory backends holding the given passwords per
+// provider.
+func fakeBackends(providers map[string]map[string]string) BackendFunc {
+	backends := make(map[string]backend.Backend, len(providers))
+	for provider, passwords := range providers {
+		backends[provider] = backend.NewMemoryBackend(passwords)
+	}
+	return func(provider string) (backend.Backend, error) {
+		b, ok := backends[provider]
+		if !ok {
+			return nil, fmt.Errorf("unknown backend: %s", provider)
+		}
+		return b, nil
+	}
+}
+
+const testConfig = `
+[[keys]]
+name = "API_TOKEN"
+
+[[keys]]
+name = "DB_PASSWORD"
+provider = "1password"
+
+[[keys]]
+name = "LOG_LEVEL"
+default = "info"
+`
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := writeConfig(t, testConfig)
+	values, err := Load(context.Background(), Options{
+		Dir: dir,
+		NewBackend: fakeBackends(map[string]map[string]string{
+			"keychain":  {"API_TOKEN": "token"},
+			"1password": {"DB_PASSWORD": "hunter2"},
+		}),
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := map[string]string{"API_TOKEN": "token", "DB_PASSWORD": "hunter2", "LOG_LEVEL": "info"}
+	if len(values) != len(want) {
+		t.Fatalf("Load = %v, want %v", values, want)
+	}
+	for k, v := range want {
+		if values[k] != v {
+			t.Fatalf("Load()[%s] = %q, want %q", k, values[k], v)
+		}
+	}
+}
+
+func TestLoadReportsEveryMissingKey(t *testing.T) {
+	t.Parallel()
+
+	dir := writeConfig(t, testConfig)
+	values, err := Load(context.Background(), Options{
+		Dir:        dir,
+		Keys:       []string{"API_TOKEN", "DB_PASSWORD", "OTHER"},
+		NewBackend: fakeBackends(map[string]map[string]string{"keychain": {"API_TOKEN": "token"}}),
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, key := range []string{"DB_PASSWORD", "OTHER"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q does not mention %s", err, key)
+		}
+	}
+	if !errors.Is(err, backend.ErrNotFound) {
+		t.Errorf("expected error to wrap ErrNotFound: %v", err)
+	}
+	var backendErr *BackendError
+	if !errors.As(err, &backendErr) || backendErr.Provider != "1password" {
+		t.Errorf("expected a BackendError for 1password: %v", err)
+	}
+	if values["API_TOKEN"] != "token" {
+		t.Errorf("resolved values should still be returned, got %v", values)
+	}
+}
+
+func TestLoadWithoutConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := Load(context.Background(), Options{Dir: t.TempDir(), NewBackend: fakeBackends(nil)})
+	if !errors.Is(err, ErrNoConfig) {
+		t.Fatalf("expected ErrNoConfig, got %v", err)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	dir := writeConfig(t, testConfig)
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("API_TOKEN", "")
+	os.Unsetenv("API_TOKEN")
+
+	err := LoadEnv(context.Background(), Options{
+		Dir:        dir,
+		Keys:       []string{"API_TOKEN", "LOG_LEVEL"},
+		NewBackend: fakeBackends(map[string]map[string]string{"keychain": {"API_TOKEN": "token"}}),
+	})
+	if err != nil {
+		t.Fatalf("LoadEnv: %v", err)
+	}
+	if got := os.Getenv("API_TOKEN"); got != "token" {
+		t.Fatalf("API_TOKEN = %q, want token", got)
+	}
+	if got := os.Getenv("LOG_LEVEL"); got != "debug" {
+		t.Fatalf("LOG_LEVEL = %q, existing value should be kept", got)
+	}
+}
+
+func TestResolveReference(t *testing.T) {
+	t.Parallel()
+
+	r := NewResolver(nil, "keychain", fakeBackends(map[string]map[string]string{
+		"keychain":  {"A": "from-keychain"},
+		"1password": {"A": "from-1password"},
+	}))
+	out, err := ref.Replace("chainenv://A chainenv://1password/A", r.ResolveReference)
+	if err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if out != "from-keychain from-1password" {
+		t.Fatalf("Replace = %q", out)
+	}
+}
@@ -0,0 +1,237 @@
+package chainenv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/config"
+	"github.com/dvcrn/chainenv/ref"
+)
+
+// Defaults used when Options leave the provider or vault empty.
+const (
+	DefaultProvider = "keychain"
+	DefaultVault    = "chainenv"
+)
+
+// BackendFunc initializes the backend for a provider.
+type BackendFunc func(provider string) (backend.Backend, error)
+
+// BackendError is returned when the backend of a provider can't be
+// initialized, e.g. because no keyring is available.
+type BackendError struct {
+	Provider string
+	Err      error
+}
+
+func (e *BackendError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// FindConfig loads .chainenv.toml or chainenv.toml from dir. It returns nil
+// without an error if neither exists.
+func FindConfig(dir string) (*config.Config, error) {
+	path, ok, err := config.FindConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return config.Load(path)
+}
+
+// KeyNames returns the names of all keys declared in cfg, skipping entries
+// without a name.
+func KeyNames(cfg *config.Config) []string {
+	if cfg == nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range cfg.Keys {
+		if entry.Name == "" {
+			continue
+		}
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+// KeyConfig returns the provider configured for name in cfg, or
+// fallbackProvider if it has none, and its default value if any.
+func KeyConfig(cfg *config.Config, name, fallbackProvider string) (provider string, defaultValue *string) {
+	provider = fallbackProvider
+	if cfg == nil {
+		return provider, nil
+	}
+
+	if entry, ok := cfg.FindKey(name); ok {
+		if entry.Provider != "" {
+			provider = entry.Provider
+		}
+		defaultValue = entry.Default
+	}
+
+	return provider, defaultValue
+}
+
+// NewBackend initializes the backend for provider in this process. For
+// 1Password, the service account token configured in cfg is loaded first.
+func NewBackend(provider, vault string, cfg *config.Config, opts ...backend.BackendOption) (backend.Backend, error) {
+	switch provider {
+	case "keychain":
+		b, err := backend.NewKeychainBackend()
+		if err != nil {
+			return nil, fmt.Errorf("keychain backend unavailable: %w", err)
+		}
+		return b, nil
+	case "1password":
+		if err := EnsureOpServiceAccountToken(cfg); err != nil {
+			return nil, err
+		}
+		return backend.NewOnePasswordBackend(vault, opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown backend: %s", provider)
+	}
+}
+
+// OpServiceAccountTokenKey returns the keychain item cfg names for the 1Password
+// service account token, or "" if there is none.
+func OpServiceAccountTokenKey(cfg *config.Config) string {
+	if cfg == nil || cfg.OnePassword == nil {
+		return ""
+	}
+	return cfg.OnePassword.ServiceAccountTokenKey
+}
+
+// EnsureOpServiceAccountToken sets OP_SERVICE_ACCOUNT_TOKEN from the keychain
+// item named by cfg's 1Password service_account_token_key, unless it is
+// already set.
+func EnsureOpServiceAccountToken(cfg *config.Config) error {
+	if os.Getenv("OP_SERVICE_ACCOUNT_TOKEN") != "" {
+		return nil
+	}
+	tokenKey := OpServiceAccountTokenKey(cfg)
+	if tokenKey == "" {
+		return nil
+	}
+
+	keychain, err := backend.NewKeychainBackend()
+	if err != nil {
+		return fmt.Errorf("keychain backend unavailable: %w", err)
+	}
+
+	token, err := keychain.GetPassword(tokenKey)
+	if err != nil {
+		return fmt.Errorf("failed to load %s from keychain: %w", tokenKey, err)
+	}
+
+	if err := os.Setenv("OP_SERVICE_ACCOUNT_TOKEN", token); err != nil {
+		return fmt.Errorf("failed to set OP_SERVICE_ACCOUNT_TOKEN: %w", err)
+	}
+	return nil
+}
+
+// Resolver resolves keys through the providers configured for them, falling
+// back to configured defaults. Each backend is initialized once, on first use.
+type Resolver struct {
+	config     *config.Config
+	provider   string
+	newBackend BackendFunc
+	backends   map[string]backend.Backend
+}
+
+// NewResolver returns a Resolver for cfg, which may be nil. Keys without a
+// configured provider are looked up in provider.
+func NewResolver(cfg *config.Config, provider string, newBackend BackendFunc) *Resolver {
+	return &Resolver{
+		config:     cfg,
+		provider:   provider,
+		newBackend: newBackend,
+		backends:   make(map[string]backend.Backend),
+	}
+}
+
+// KeyConfig returns the provider and default value of key.
+func (r *Resolver) KeyConfig(key string) (provider string, defaultValue *string) {
+	return KeyConfig(r.config, key, r.provider)
+}
+
+// Backend returns the backend for provider. Initialization failures are
+// returned as a *BackendError.
+func (r *Resolver) Backend(provider string) (backend.Backend, error) {
+	if cached, ok := r.backends[provider]; ok {
+		return cached, nil
+	}
+	b, err := r.newBackend(provider)
+	if err != nil {
+		return nil, &BackendError{Provider: provider, Err: err}
+	}
+	r.backends[provider] = b
+	return b, nil
+}
+
+// Resolve looks up key through its configured provider and falls back to the
+// configured default when the secret is missing. usedDefault reports whether
+// the default was returned.
+func (r *Resolver) Resolve(key string) (value string, usedDefault bool, err error) {
+	return r.ResolveWithProvider(key, "")
+}
+
+// ResolveWithProvider is like Resolve but looks the key up in provider
+// instead of the configured one, unless provider is empty.
+func (r *Resolver) ResolveWithProvider(key, provider string) (value string, usedDefault bool, err error) {
+	configured, defaultValue := r.KeyConfig(key)
+	if provider == "" {
+		provider = configured
+	}
+	b, err := r.Backend(provider)
+	if err != nil {
+		return "", false, err
+	}
+
+	password, err := b.GetPassword(key)
+	if err != nil {
+		if errors.Is(err, backend.ErrNotFound) && defaultValue != nil {
+			return *defaultValue, true, nil
+		}
+		return "", false, err
+	}
+	return password, false, nil
+}
+
+// ResolveAll resolves every key with Resolve. Values that could be resolved
+// are always returned alongside the first error encountered.
+func (r *Resolver) ResolveAll(keys []string) (map[string]string, error) {
+	values := make(map[string]string)
+	var firstErr error
+	for _, key := range keys {
+		value, _, err := r.Resolve(key)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", key, err)
+			}
+			continue
+		}
+		values[key] = value
+	}
+
+	return values, firstErr
+}
+
+// ResolveReference resolves a chainenv:// reference through its explicit
+// provider or the configured one. It can be used as a ref.ResolveFunc.
+func (r *Resolver) ResolveReference(reference ref.Reference) (string, error) {
+	value, _, err := r.ResolveWithProvider(reference.Key, reference.Provider)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", reference, err)
+	}
+	return value, nil
+}
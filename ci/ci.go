@@ -0,0 +1,93 @@
+package ci
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	GitHub    = "github"
+	GitLab    = "gitlab"
+	Buildkite = "buildkite"
+)
+
+// Providers lists the supported CI providers.
+var Providers = []string{GitHub, GitLab, Buildkite}
+
+// Detect returns the CI provider the process is running in, based on the
+// environment variables each provider sets.
+func Detect() (string, bool) {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return GitHub, true
+	case os.Getenv("GITLAB_CI") == "true":
+		return GitLab, true
+	case os.Getenv("BUILDKITE") == "true":
+		return Buildkite, true
+	default:
+		return "", false
+	}
+}
+
+// WriteGitHubMasks writes ::add-mask:: workflow commands for value. GitHub
+// masks line by line, so every line of a multiline value is masked separately.
+func WriteGitHubMasks(w io.Writer, value string) error {
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "::add-mask::%s\n", escapeGitHubData(line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteGitHubEnv writes key=value in the $GITHUB_ENV file format, using the
+// heredoc delimiter syntax so that multiline values are preserved.
+func WriteGitHubEnv(w io.Writer, key, value string) error {
+	delimiter, err := randomDelimiter()
+	if err != nil {
+		return err
+	}
+	for strings.Contains(value, delimiter) {
+		if delimiter, err = randomDelimiter(); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "%s<<%s\n%s\n%s\n", key, delimiter, value, delimiter)
+	return err
+}
+
+// AddBuildkiteRedaction registers value with the Buildkite agent's redactor,
+// so it is masked in the job log from now on.
+func AddBuildkiteRedaction(value string) error {
+	c := exec.Command("buildkite-agent", "redactor", "add")
+	c.Stdin = strings.NewReader(value)
+	if output, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("buildkite-agent redactor add: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// escapeGitHubData escapes workflow command data as done by @actions/core.
+func escapeGitHubData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+func randomDelimiter() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("read random: %w", err)
+	}
+	return "ghadelimiter_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,51 @@
+package ci
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "")
+	t.Setenv("GITLAB_CI", "")
+	t.Setenv("BUILDKITE", "")
+
+	if p, ok := Detect(); ok {
+		t.Fatalf("expected no provider, got %s", p)
+	}
+
+	t.Setenv("GITLAB_CI", "true")
+	if p, ok := Detect(); !ok || p != GitLab {
+		t.Fatalf("expected gitlab, got %q", p)
+	}
+}
+
+func TestWriteGitHubMasks(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := WriteGitHubMasks(&buf, "-----BEGIN KEY-----\r\nab%cd\n\n-----END KEY-----"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	want := "::add-mask::-----BEGIN KEY-----\n::add-mask::ab%25cd\n::add-mask::-----END KEY-----\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestWriteGitHubEnv(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := WriteGitHubEnv(&buf, "CERT", "line1\nline2"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	re := regexp.MustCompile(`^CERT<<(ghadelimiter_[0-9a-f]{32})\nline1\nline2\n(ghadelimiter_[0-9a-f]{32})\n$`)
+	m := re.FindStringSubmatch(buf.String())
+	if m == nil || m[1] != m[2] {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
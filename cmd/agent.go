@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dvcrn/chainenv/agent"
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	agentSocket      string
+	agentIdleTimeout time.Duration
+	agentCacheTTL    time.Duration
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Run a local agent that keeps backends initialized",
+	Long: `Run a local agent that holds initialized backends and caches secrets in memory, serving them
+over a Unix socket that only the current user can access. Other chainenv commands use the agent
+when ` + agent.SockEnv + ` points at its socket, e.g.:
+  chainenv agent &
+  export ` + agent.SockEnv + `=<socket printed by the agent>
+
+The 1Password account is chosen by the service_account_token_key of the client's config. An agent
+serves a single account: run one agent per account, each with its own --socket.
+
+The agent exits after --idle-timeout without requests.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		socket := agentSocket
+		if socket == "" {
+			socket = agent.DefaultSocketPath()
+		}
+
+		l, err := agent.Listen(socket)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", socket, err)
+		}
+		defer os.Remove(socket)
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		go func() {
+			<-signals
+			l.Close()
+		}()
+
+		fmt.Fprintf(cmd.OutOrStdout(), "export %s=%s\n", agent.SockEnv, socket)
+
+		server := agent.NewServer(newAgentFactory(), agentCacheTTL, agentIdleTimeout)
+		if err := server.Serve(l); err != nil && !errors.Is(err, net.ErrClosed) {
+			return fmt.Errorf("agent stopped: %w", err)
+		}
+		log.Debug("Agent stopped")
+		return nil
+	},
+}
+
+// newAgentFactory returns the factory the agent creates backends with. The
+// agent must never talk to itself, so it creates backends directly. Its
+// 1Password settings come from the client's config, not the agent's working
+// directory. op reads the service account token from the environment of the
+// whole process, so once a 1Password backend exists, requests for another
+// token key are refused instead of silently using the wrong account.
+func newAgentFactory() agent.Factory {
+	var opTokenKey *string
+	return func(provider, vault, tokenKey string) (backend.Backend, error) {
+		var cfg *config.Config
+		if provider == "1password" {
+			if opTokenKey != nil && *opTokenKey != tokenKey {
+				return nil, fmt.Errorf("agent serves the 1Password account of service_account_token_key %q, not %q; start a separate agent for it", *opTokenKey, tokenKey)
+			}
+			cfg = &config.Config{OnePassword: &config.OnePasswordConfig{ServiceAccountTokenKey: tokenKey}}
+		}
+
+		b, err := backendFactory(provider, vault, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if provider == "1password" {
+			opTokenKey = &tokenKey
+		}
+		return backend.Observe(b, log.AddSecrets), nil
+	}
+}
+
+func init() {
+	agentCmd.Flags().StringVar(&agentSocket, "socket", "", "Socket path (default in $XDG_RUNTIME_DIR or the temp dir)")
+	agentCmd.Flags().DurationVar(&agentIdleTimeout, "idle-timeout", 30*time.Minute, "Exit after this long without requests (0 to never exit)")
+	agentCmd.Flags().DurationVar(&agentCacheTTL, "cache-ttl", 5*time.Minute, "How long to cache secrets in memory (0 to disable)")
+	rootCmd.AddCommand(agentCmd)
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dvcrn/chainenv/audit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditFile  string
+	auditKey   string
+	auditOp    string
+	auditSince string
+	auditUntil string
+	auditJSON  bool
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect secret access and hygiene",
+}
+
+var auditLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show the audit log of secret access",
+	Long: `Show which secrets were accessed, when, by which command and from which directory.
+Auditing is enabled by pointing ` + audit.PathEnv + ` at a log file. Values are never recorded, e.g.:
+  export ` + audit.PathEnv + `=~/.local/state/chainenv/audit.jsonl
+  chainenv audit log --key GITHUB_TOKEN --since 24h
+  chainenv audit log --op set --since 2025-01-01 --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := auditFile
+		if path == "" {
+			path = os.Getenv(audit.PathEnv)
+		}
+		if path == "" {
+			return fmt.Errorf("no audit log configured, set %s or use --file", audit.PathEnv)
+		}
+
+		filter := audit.Filter{Key: auditKey, Op: auditOp}
+		var err error
+		if filter.Since, err = parseTimeFlag(auditSince, false); err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		if filter.Until, err = parseTimeFlag(auditUntil, true); err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+
+		entries, err := audit.Open(path).Read(filter)
+		if err != nil {
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+
+		out := cmd.OutOrStdout()
+		if auditJSON {
+			enc := json.NewEncoder(out)
+			for _, e := range entries {
+				if err := enc.Encode(e); err != nil {
+					return fmt.Errorf("error encoding entry: %w", err)
+				}
+			}
+			return nil
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tOP\tKEY\tPROVIDER\tOUTCOME\tCOMMAND\tPARENT\tCWD")
+		for _, e := range entries {
+			key := e.Key
+			if len(e.Keys) > 0 {
+				key = strings.Join(e.Keys, ",")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				e.Time.Local().Format(time.DateTime), e.Op, key, e.Provider, e.Outcome, e.Command, e.Parent, e.Cwd)
+		}
+		return w.Flush()
+	},
+}
+
+// parseTimeFlag parses a point in time given as a duration relative to now
+// (e.g. "24h"), a date or an RFC 3339 timestamp. A date stands for its start,
+// or its last instant if endOfDay is set, so that it is an inclusive bound
+// either way. An empty value yields the zero time.
+func parseTimeFlag(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a duration, a date (YYYY-MM-DD) nor an RFC 3339 timestamp", value)
+}
+
+func init() {
+	auditLogCmd.Flags().StringVar(&auditFile, "file", "", "Audit log to read (default $"+audit.PathEnv+")")
+	auditLogCmd.Flags().StringVar(&auditKey, "key", "", "Only show entries for this key")
+	auditLogCmd.Flags().StringVar(&auditOp, "op", "", "Only show this operation (get, get-multiple, set, update, list, delete)")
+	auditLogCmd.Flags().StringVar(&auditSince, "since", "", "Only show entries after this time (e.g. 24h, 2025-01-01, RFC 3339)")
+	auditLogCmd.Flags().StringVar(&auditUntil, "until", "", "Only show entries up to this time (e.g. 1h, 2025-01-31 including that day, RFC 3339)")
+	auditLogCmd.Flags().BoolVar(&auditJSON, "json", false, "Output entries as JSON lines")
+	auditCmd.AddCommand(auditLogCmd)
+	rootCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/chainenv"
+	"github.com/dvcrn/chainenv/config"
+	"github.com/dvcrn/chainenv/dockercred"
+	"github.com/dvcrn/chainenv/hygiene"
+	"github.com/spf13/cobra"
+)
+
+var (
+	hygieneStaleDays int
+	hygieneConfigs   []string
+	hygieneJSON      bool
+)
+
+var auditSecretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Report weak, reused, placeholder, stale and unused secrets",
+	Long: `Read every secret stored in the backend and report values that are short or low in entropy,
+identical values stored under several keys, values that look like placeholders, keys not modified
+in --stale-days (where the backend records modification times) and keys not referenced by any config.
+Values are never printed. Exits with a non-zero status if anything is reported, e.g.:
+  chainenv audit secrets --backend 1password --stale-days 90
+  chainenv audit secrets --config ~/src/api/.chainenv.toml --config ~/src/web/.chainenv.toml --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		b, err := getBackendWithType(backendType)
+		if err != nil {
+			return fmt.Errorf("error initializing backend: %w", err)
+		}
+
+		keys, err := b.List()
+		if err != nil {
+			return fmt.Errorf("error listing accounts: %w", err)
+		}
+		values, err := readPasswords(b, keys)
+		if err != nil {
+			return fmt.Errorf("error reading secrets: %w", err)
+		}
+
+		opts := hygiene.Options{StaleAfter: time.Duration(hygieneStaleDays) * 24 * time.Hour}
+		modTimes, ok, err := backend.ModTimes(b)
+		switch {
+		case err != nil:
+			return fmt.Errorf("error reading modification times: %w", err)
+		case ok:
+			opts.ModTimes = modTimes
+		default:
+			log.Debug("Backend %s does not record modification times, skipping stale check", backendType)
+		}
+
+		opts.Referenced, err = referencedKeys(hygieneConfigs, keys)
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+		if opts.Referenced == nil {
+			log.Debug("No config found, skipping unreferenced check")
+		}
+
+		findings := hygiene.Analyze(values, opts)
+
+		out := cmd.OutOrStdout()
+		if hygieneJSON {
+			if findings == nil {
+				findings = []hygiene.Finding{}
+			}
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(findings); err != nil {
+				return fmt.Errorf("error encoding findings: %w", err)
+			}
+		} else if len(findings) == 0 {
+			fmt.Fprintf(out, "No issues found in %d secrets\n", len(values))
+		} else {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tCHECK\tDETAIL")
+			for _, f := range findings {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", f.Key, f.Check, f.Detail)
+			}
+			w.Flush()
+		}
+
+		if len(findings) > 0 {
+			return exitCode(1)
+		}
+		return nil
+	},
+}
+
+// referencedKeys returns the keys declared in the configs at paths, or in the
+// discovered config if paths is empty. Previous values kept by rotate count as
+// referenced along with their key, and so do the keys of git credentials, the
+// 1Password service account token and the stored keys of the docker
+// credential helper. It returns nil if no config is known.
+func referencedKeys(paths, stored []string) (map[string]bool, error) {
+	var configs []*config.Config
+	if len(paths) == 0 {
+		cfg, err := loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		if cfg != nil {
+			configs = append(configs, cfg)
+		}
+	}
+	for _, path := range paths {
+		cfg, err := config.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		configs = append(configs, cfg)
+	}
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	referenced := make(map[string]bool)
+	for _, cfg := range configs {
+		for _, name := range chainenv.KeyNames(cfg) {
+			referenced[name] = true
+			referenced[name+previousSuffix] = true
+		}
+		for _, cred := range cfg.GitCredentials {
+			referenced[cred.Key] = true
+		}
+		if tokenKey := chainenv.OpServiceAccountTokenKey(cfg); tokenKey != "" {
+			referenced[tokenKey] = true
+		}
+	}
+	for _, key := range stored {
+		if strings.HasPrefix(key, dockercred.KeyPrefix) {
+			referenced[key] = true
+		}
+	}
+	return referenced, nil
+}
+
+func init() {
+	auditSecretsCmd.Flags().IntVar(&hygieneStaleDays, "stale-days", 180, "Report keys not modified in this many days (0 disables)")
+	auditSecretsCmd.Flags().StringSliceVar(&hygieneConfigs, "config", nil, "Configs whose keys count as referenced (default the discovered config)")
+	auditSecretsCmd.Flags().BoolVar(&hygieneJSON, "json", false, "Output findings as JSON")
+	auditCmd.AddCommand(auditSecretsCmd)
+}
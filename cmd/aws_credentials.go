@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	awsAccessKeyIDKey     string
+	awsSecretAccessKeyKey string
+	awsSessionTokenKey    string
+	awsExpirationKey      string
+)
+
+// awsProcessCredentials is the output of an AWS credential_process.
+type awsProcessCredentials struct {
+	Version         int
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	SessionToken    string `json:",omitempty"`
+	Expiration      string `json:",omitempty"`
+}
+
+var awsCredentialsCmd = &cobra.Command{
+	Use:   "aws-credentials",
+	Short: "Print AWS credentials for credential_process",
+	Long: `Print the keys holding an AWS access key as the JSON document expected by credential_process,
+so AWS SDKs and the CLI read them from the backend instead of ~/.aws/credentials, e.g. in ~/.aws/config:
+  [profile work]
+  credential_process = chainenv aws-credentials --access-key-id AWS_ACCESS_KEY_ID --secret-access-key AWS_SECRET_ACCESS_KEY
+
+With --expiration, the key must hold an RFC 3339 timestamp after which the SDK asks for new credentials.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+
+		keys := []string{awsAccessKeyIDKey, awsSecretAccessKeyKey}
+		if awsSessionTokenKey != "" {
+			keys = append(keys, awsSessionTokenKey)
+		}
+		resolver := newResolver(cfg)
+		values, err := resolver.ResolveAll(keys)
+		if err != nil {
+			return fmt.Errorf("error getting credentials: %w", err)
+		}
+
+		creds := awsProcessCredentials{
+			Version:         1,
+			AccessKeyID:     values[awsAccessKeyIDKey],
+			SecretAccessKey: values[awsSecretAccessKeyKey],
+		}
+		if awsSessionTokenKey != "" {
+			creds.SessionToken = values[awsSessionTokenKey]
+		}
+		if awsExpirationKey != "" {
+			expiration, err := resolveExpiration(resolver, awsExpirationKey)
+			if err != nil {
+				return fmt.Errorf("error getting expiration: %w", err)
+			}
+			creds.Expiration = expiration.UTC().Format(time.RFC3339)
+		}
+
+		out, err := json.Marshal(creds)
+		if err != nil {
+			return fmt.Errorf("error encoding credentials: %w", err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(out))
+		return nil
+	},
+}
+
+func init() {
+	awsCredentialsCmd.Flags().StringVar(&awsAccessKeyIDKey, "access-key-id", "", "Key holding the access key ID")
+	awsCredentialsCmd.Flags().StringVar(&awsSecretAccessKeyKey, "secret-access-key", "", "Key holding the secret access key")
+	awsCredentialsCmd.Flags().StringVar(&awsSessionTokenKey, "session-token", "", "Key holding the session token")
+	awsCredentialsCmd.Flags().StringVar(&awsExpirationKey, "expiration", "", "Key holding the RFC 3339 expiration time of the credentials")
+	awsCredentialsCmd.MarkFlagRequired("access-key-id")
+	awsCredentialsCmd.MarkFlagRequired("secret-access-key")
+	rootCmd.AddCommand(awsCredentialsCmd)
+}
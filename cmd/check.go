@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/chainenv"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of the check command.
+const (
+	checkExitMissing = 1
+	checkExitError   = 2
+)
+
+const (
+	checkFound   = "found"
+	checkDefault = "missing-using-default"
+	checkMissing = "missing"
+	checkError   = "error"
+)
+
+var checkJSON bool
+
+type checkResult struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+type checkReport struct {
+	OK   bool          `json:"ok"`
+	Keys []checkResult `json:"keys"`
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Verify that all keys in config resolve",
+	Long: `Resolve every key declared in config through its provider without printing values,
+and report whether it was found, fell back to its default, is missing, or failed.
+
+Exit codes:
+  0  every key was found or has a default
+  1  at least one key is missing
+  2  a backend returned an error, or the config could not be loaded`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return &exitError{code: checkExitError, err: fmt.Errorf("error loading config: %w", err)}
+		}
+		if cfg == nil {
+			return &exitError{code: checkExitError, err: errors.New("no config found")}
+		}
+
+		resolver := newResolver(cfg)
+		report := checkReport{OK: true, Keys: []checkResult{}}
+		code := 0
+		seen := make(map[string]bool)
+		for _, name := range chainenv.KeyNames(cfg) {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			provider, _ := resolver.KeyConfig(name)
+			result := checkResult{Name: name, Provider: provider, Status: checkFound}
+
+			_, usedDefault, err := resolver.Resolve(name)
+			switch {
+			case err == nil && usedDefault:
+				result.Status = checkDefault
+			case errors.Is(err, backend.ErrNotFound):
+				result.Status = checkMissing
+				code = max(code, checkExitMissing)
+			case err != nil:
+				result.Status = checkError
+				result.Error = err.Error()
+				code = max(code, checkExitError)
+			}
+			report.Keys = append(report.Keys, result)
+		}
+		report.OK = code == 0
+
+		out := cmd.OutOrStdout()
+		if checkJSON {
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return &exitError{code: checkExitError, err: fmt.Errorf("error encoding report: %w", err)}
+			}
+		} else {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tPROVIDER\tSTATUS")
+			for _, r := range report.Keys {
+				status := r.Status
+				if r.Error != "" {
+					status += ": " + r.Error
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, r.Provider, status)
+			}
+			w.Flush()
+		}
+
+		if code != 0 {
+			return exitCode(code)
+		}
+		return nil
+	},
+}
+
+func init() {
+	checkCmd.Flags().BoolVar(&checkJSON, "json", false, "Output the report as JSON")
+	rootCmd.AddCommand(checkCmd)
+}
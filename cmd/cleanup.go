@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dvcrn/chainenv/secretfile"
+	"github.com/spf13/cobra"
+)
+
+var cleanupAll bool
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup [dir]",
+	Short: "Remove secret files written by get-env",
+	Long: `Remove the secret files written by get-env for keys configured with file = true.
+Without arguments, the directory in $` + secretfile.DirEnv + ` is removed, e.g.:
+  eval "$(chainenv get-env --shell bash)"
+  ...
+  chainenv cleanup`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cleanupAll {
+			if err := secretfile.RemoveAll(); err != nil {
+				return fmt.Errorf("failed to remove secret files: %w", err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Removed all secret files")
+			return nil
+		}
+
+		dir := os.Getenv(secretfile.DirEnv)
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		if dir == "" {
+			return fmt.Errorf("no directory given and $%s is not set, use --all to remove all secret files", secretfile.DirEnv)
+		}
+
+		if err := secretfile.RemoveDir(dir); err != nil {
+			return fmt.Errorf("failed to remove secret files: %w", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed %s\n", dir)
+		return nil
+	},
+}
+
+func init() {
+	cleanupCmd.Flags().BoolVar(&cleanupAll, "all", false, "Remove the secret files of all sessions")
+	rootCmd.AddCommand(cleanupCmd)
+}
@@ -0,0 +1,1319 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dvcrn/chainenv/agent"
+	"github.com/dvcrn/chainenv/audit"
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/config"
+	"github.com/dvcrn/chainenv/dockercred"
+	"github.com/dvcrn/chainenv/hygiene"
+	"github.com/dvcrn/chainenv/logger"
+	"github.com/dvcrn/chainenv/secretfile"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// harness runs commands in-process against in-memory backends, with config
+// discovered from dir.
+type harness struct {
+	t        *testing.T
+	dir      string
+	backends map[string]*backend.MemoryBackend
+}
+
+// brokenProvider is a provider whose backend fails to initialize.
+const brokenProvider = "broken"
+
+// newHarness resets all flags to their defaults and points the command seams
+// at a temporary directory and in-memory keychain and 1password backends.
+// Commands share package state, so tests using it must not run in parallel.
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	h := &harness{
+		t:   t,
+		dir: t.TempDir(),
+		backends: map[string]*backend.MemoryBackend{
+			"keychain":  backend.NewMemoryBackend(nil),
+			"1password": backend.NewMemoryBackend(nil),
+		},
+	}
+
+	resetFlags(t, rootCmd)
+	// Commands replace it, but helpers tested directly log too.
+	log = logger.New(io.Discard, logger.Options{})
+	t.Setenv(agent.SockEnv, "")
+	t.Setenv(audit.PathEnv, "")
+	t.Setenv(kubeExecInfoEnv, "")
+	// get-env detects CI providers from the environment.
+	for _, name := range []string{"GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv(secretfile.DirEnv, t.TempDir())
+
+	oldFactory, oldGetwd := backendFactory, getwd
+	t.Cleanup(func() { backendFactory, getwd = oldFactory, oldGetwd })
+	backendFactory = func(provider, vault string, _ *config.Config) (backend.Backend, error) {
+		b, ok := h.backends[provider]
+		if !ok {
+			return nil, fmt.Errorf("unknown backend: %s", provider)
+		}
+		return b, nil
+	}
+	getwd = func() (string, error) { return h.dir, nil }
+	return h
+}
+
+// resetFlags restores every flag of cmd and its subcommands to its default,
+// undoing what earlier runs of the package-level commands have parsed.
+func resetFlags(t *testing.T, cmd *cobra.Command) {
+	t.Helper()
+	reset := func(f *pflag.Flag) {
+		var err error
+		if v, ok := f.Value.(pflag.SliceValue); ok {
+			err = v.Replace(nil)
+		} else {
+			err = f.Value.Set(f.DefValue)
+		}
+		if err != nil {
+			t.Fatalf("reset --%s: %v", f.Name, err)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, c := range cmd.Commands() {
+		resetFlags(t, c)
+	}
+}
+
+func (h *harness) writeConfig(content string) {
+	h.t.Helper()
+	if err := os.WriteFile(filepath.Join(h.dir, ".chainenv.toml"), []byte(content), 0o644); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// run executes args with stdin and returns what was written to stdout and
+// stderr along with the exit code.
+func (h *harness) run(stdin string, args ...string) (string, string, int) {
+	h.t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := execute(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+const testConfig = `
+[[keys]]
+name = "API_TOKEN"
+
+[[keys]]
+name = "DB_PASSWORD"
+provider = "1password"
+
+[[keys]]
+name = "LOG_LEVEL"
+default = "info"
+`
+
+var awsSecrets = map[string]string{
+	"AWS_ID":         "AKIAEXAMPLE",
+	"AWS_SECRET":     "aws-secret",
+	"AWS_TOKEN":      "aws-token",
+	"AWS_EXPIRATION": "2030-01-01T01:00:00+01:00",
+}
+
+var kubeSecrets = map[string]string{
+	"KUBE_TOKEN":      "kube-token",
+	"KUBE_CERT":       "cert-pem",
+	"KUBE_KEY":        "key-pem",
+	"KUBE_EXPIRATION": "2030-01-01T00:00:00Z",
+}
+
+var tfSecrets = map[string]map[string]string{
+	"keychain":  {"API_TOKEN": "tok"},
+	"1password": {"DB_PASSWORD": "db"},
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		subdir   bool
+		secrets  map[string]map[string]string
+		env      map[string]string
+		stdin    string
+		args     []string
+		wantOut  string
+		wantErr  string
+		wantCode int
+	}{
+		{
+			name:    "get from default backend",
+			secrets: map[string]map[string]string{"keychain": {"API_TOKEN": "tok"}},
+			args:    []string{"get", "API_TOKEN"},
+			wantOut: "tok\n",
+		},
+		{
+			name:    "get from backend flag",
+			secrets: map[string]map[string]string{"1password": {"API_TOKEN": "op-tok"}},
+			args:    []string{"get", "API_TOKEN", "--backend", "1password"},
+			wantOut: "op-tok\n",
+		},
+		{
+			name:   "get from configured provider",
+			config: testConfig,
+			secrets: map[string]map[string]string{
+				"keychain":  {"DB_PASSWORD": "wrong"},
+				"1password": {"DB_PASSWORD": "pw"},
+			},
+			args:    []string{"get", "DB_PASSWORD"},
+			wantOut: "pw\n",
+		},
+		{
+			name:    "get falls back to default",
+			config:  testConfig,
+			args:    []string{"get", "LOG_LEVEL"},
+			wantOut: "info\n",
+		},
+		{
+			name:     "get missing key",
+			args:     []string{"get", "API_TOKEN"},
+			wantErr:  "ERR: error retrieving password: " + backend.ErrNotFound.Error(),
+			wantCode: 1,
+		},
+		{
+			name:     "get unknown backend",
+			args:     []string{"get", "API_TOKEN", "--backend", brokenProvider},
+			wantErr:  "ERR: error initializing backend: unknown backend: " + brokenProvider,
+			wantCode: 1,
+		},
+		{
+			name:     "get without key",
+			args:     []string{"get"},
+			wantErr:  "ERR: accepts 1 arg(s), received 0",
+			wantCode: 1,
+		},
+		{
+			name:   "get-env resolves config keys",
+			config: testConfig,
+			secrets: map[string]map[string]string{
+				"keychain":  {"API_TOKEN": "tok"},
+				"1password": {"DB_PASSWORD": "pw"},
+			},
+			args:    []string{"get-env", "--shell", "bash"},
+			wantOut: "export API_TOKEN='tok'\nexport DB_PASSWORD='pw'\nexport LOG_LEVEL='info'\n",
+		},
+		{
+			name:   "get-env discovers config in parent directory",
+			config: testConfig,
+			subdir: true,
+			secrets: map[string]map[string]string{
+				"keychain": {"API_TOKEN": "tok"},
+			},
+			args:    []string{"get-env", "API_TOKEN,LOG_LEVEL"},
+			wantOut: "API_TOKEN='tok'\nLOG_LEVEL='info'\n",
+		},
+		{
+			name:     "get-env without config",
+			args:     []string{"get-env"},
+			wantErr:  "No config found\n",
+			wantCode: 1,
+		},
+		{
+			name:     "get-env without values",
+			args:     []string{"get-env", "API_TOKEN"},
+			wantErr:  "No passwords found\nAPI_TOKEN: ",
+			wantCode: 1,
+		},
+		{
+			name:    "list",
+			config:  testConfig,
+			args:    []string{"list"},
+			wantOut: "API_TOKEN\nDB_PASSWORD\nLOG_LEVEL\n",
+		},
+		{
+			name:    "ls",
+			secrets: map[string]map[string]string{"keychain": {"B": "2", "A": "1"}},
+			args:    []string{"ls"},
+			wantOut: "A\nB\n",
+		},
+		{
+			name:    "inject",
+			secrets: map[string]map[string]string{"1password": {"API_TOKEN": "tok"}},
+			stdin:   "token: chainenv://1password/API_TOKEN\n",
+			args:    []string{"inject"},
+			wantOut: "token: tok\n",
+		},
+		{
+			name:    "copy",
+			secrets: map[string]map[string]string{"keychain": {"A": "1", "B": "2"}},
+			args:    []string{"copy", "A,B", "--from", "keychain", "--to", "1password"},
+			wantOut: "Copied password for A from keychain to 1password\nCopied password for B from keychain to 1password\n",
+		},
+		{
+			name: "copy without overwrite",
+			secrets: map[string]map[string]string{
+				"keychain":  {"A": "1"},
+				"1password": {"A": "old"},
+			},
+			args:     []string{"copy", "A", "--from", "keychain", "--to", "1password"},
+			wantErr:  "ERR: Failed to copy password for A: item 'A' already exists. Use --overwrite to overwrite existing items.\n",
+			wantCode: 1,
+		},
+		{
+			name:   "check succeeds",
+			config: testConfig,
+			secrets: map[string]map[string]string{
+				"keychain":  {"API_TOKEN": "tok"},
+				"1password": {"DB_PASSWORD": "pw"},
+			},
+			args:    []string{"check"},
+			wantOut: "KEY          PROVIDER   STATUS\nAPI_TOKEN    keychain   found\nDB_PASSWORD  1password  found\nLOG_LEVEL    keychain   missing-using-default\n",
+		},
+		{
+			name:   "check missing key",
+			config: testConfig,
+			secrets: map[string]map[string]string{
+				"keychain": {"API_TOKEN": "tok"},
+			},
+			args:     []string{"check"},
+			wantOut:  "DB_PASSWORD  1password  missing\n",
+			wantCode: checkExitMissing,
+		},
+		{
+			name:     "check backend error",
+			config:   testConfig,
+			args:     []string{"check", "--backend", brokenProvider},
+			wantOut:  "API_TOKEN    broken     error: ",
+			wantCode: checkExitError,
+		},
+		{
+			name:     "check without config",
+			args:     []string{"check"},
+			wantErr:  "ERR: no config found\n",
+			wantCode: checkExitError,
+		},
+		{
+			name:     "check key listed twice",
+			config:   "[[keys]]\nname = \"DB_PASSWORD\"\nprovider = \"1password\"\n" + testConfig,
+			secrets:  map[string]map[string]string{"keychain": {"API_TOKEN": "tok"}},
+			args:     []string{"check"},
+			wantOut:  "KEY          PROVIDER   STATUS\nDB_PASSWORD  1password  missing\nAPI_TOKEN    keychain   found\nLOG_LEVEL    keychain   missing-using-default\n",
+			wantCode: checkExitMissing,
+		},
+		{
+			name:    "check json",
+			config:  testConfig,
+			secrets: map[string]map[string]string{"keychain": {"API_TOKEN": "tok"}},
+			args:    []string{"check", "--json"},
+			wantOut: `{
+  "ok": false,
+  "keys": [
+    {
+      "name": "API_TOKEN",
+      "provider": "keychain",
+      "status": "found"
+    },
+    {
+      "name": "DB_PASSWORD",
+      "provider": "1password",
+      "status": "missing"
+    },
+    {
+      "name": "LOG_LEVEL",
+      "provider": "keychain",
+      "status": "missing-using-default"
+    }
+  ]
+}
+`,
+			wantCode: checkExitMissing,
+		},
+		{
+			name:     "check invalid config",
+			config:   "[[keys]\n",
+			args:     []string{"check"},
+			wantErr:  "ERR: error loading config: ",
+			wantCode: checkExitError,
+		},
+		{
+			name:    "aws-credentials",
+			secrets: map[string]map[string]string{"keychain": awsSecrets},
+			args:    []string{"aws-credentials", "--access-key-id", "AWS_ID", "--secret-access-key", "AWS_SECRET"},
+			wantOut: `{"Version":1,"AccessKeyId":"AKIAEXAMPLE","SecretAccessKey":"aws-secret"}` + "\n",
+		},
+		{
+			name:    "aws-credentials with session token and expiration",
+			secrets: map[string]map[string]string{"keychain": awsSecrets},
+			args: []string{
+				"aws-credentials", "--access-key-id", "AWS_ID", "--secret-access-key", "AWS_SECRET",
+				"--session-token", "AWS_TOKEN", "--expiration", "AWS_EXPIRATION",
+			},
+			wantOut: `{"Version":1,"AccessKeyId":"AKIAEXAMPLE","SecretAccessKey":"aws-secret","SessionToken":"aws-token","Expiration":"2030-01-01T00:00:00Z"}` + "\n",
+		},
+		{
+			name:     "aws-credentials without secret access key",
+			secrets:  map[string]map[string]string{"keychain": awsSecrets},
+			args:     []string{"aws-credentials", "--access-key-id", "AWS_ID"},
+			wantErr:  `ERR: required flag(s) "secret-access-key" not set`,
+			wantCode: 1,
+		},
+		{
+			name:     "aws-credentials with arguments",
+			secrets:  map[string]map[string]string{"keychain": awsSecrets},
+			args:     []string{"aws-credentials", "AWS_ID", "--access-key-id", "AWS_ID", "--secret-access-key", "AWS_SECRET"},
+			wantErr:  "ERR: unknown command",
+			wantCode: 1,
+		},
+		{
+			name:     "aws-credentials with missing key",
+			secrets:  map[string]map[string]string{"keychain": awsSecrets},
+			args:     []string{"aws-credentials", "--access-key-id", "AWS_ID", "--secret-access-key", "MISSING"},
+			wantErr:  "ERR: error getting credentials: MISSING",
+			wantCode: 1,
+		},
+		{
+			name:     "aws-credentials with invalid expiration",
+			secrets:  map[string]map[string]string{"keychain": awsSecrets},
+			args:     []string{"aws-credentials", "--access-key-id", "AWS_ID", "--secret-access-key", "AWS_SECRET", "--expiration", "AWS_SECRET"},
+			wantErr:  "ERR: error getting expiration: AWS_SECRET does not hold an RFC 3339 timestamp",
+			wantCode: 1,
+		},
+		{
+			name:    "kube-credential with token",
+			secrets: map[string]map[string]string{"keychain": kubeSecrets},
+			args:    []string{"kube-credential", "KUBE_TOKEN"},
+			wantOut: `{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{"token":"kube-token"}}` + "\n",
+		},
+		{
+			name:    "kube-credential with client certificate and expiration",
+			secrets: map[string]map[string]string{"keychain": kubeSecrets},
+			args:    []string{"kube-credential", "--client-certificate", "KUBE_CERT", "--client-key", "KUBE_KEY", "--expiration", "KUBE_EXPIRATION"},
+			wantOut: `{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{"expirationTimestamp":"2030-01-01T00:00:00Z","clientCertificateData":"cert-pem","clientKeyData":"key-pem"}}` + "\n",
+		},
+		{
+			name:    "kube-credential with v1beta1 exec info",
+			secrets: map[string]map[string]string{"keychain": kubeSecrets},
+			env:     map[string]string{kubeExecInfoEnv: `{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","spec":{"interactive":false}}`},
+			args:    []string{"kube-credential", "KUBE_TOKEN"},
+			wantOut: `{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","status":{"token":"kube-token"}}` + "\n",
+		},
+		{
+			name:    "kube-credential with exec info without version",
+			secrets: map[string]map[string]string{"keychain": kubeSecrets},
+			env:     map[string]string{kubeExecInfoEnv: `{"kind":"ExecCredential"}`},
+			args:    []string{"kube-credential", "KUBE_TOKEN"},
+			wantOut: `{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{"token":"kube-token"}}` + "\n",
+		},
+		{
+			name:     "kube-credential with unsupported exec info version",
+			secrets:  map[string]map[string]string{"keychain": kubeSecrets},
+			env:      map[string]string{kubeExecInfoEnv: `{"apiVersion":"client.authentication.k8s.io/v1alpha1"}`},
+			args:     []string{"kube-credential", "KUBE_TOKEN"},
+			wantErr:  `ERR: invalid KUBERNETES_EXEC_INFO: unsupported apiVersion "client.authentication.k8s.io/v1alpha1"`,
+			wantCode: 1,
+		},
+		{
+			name:     "kube-credential with malformed exec info",
+			secrets:  map[string]map[string]string{"keychain": kubeSecrets},
+			env:      map[string]string{kubeExecInfoEnv: `{`},
+			args:     []string{"kube-credential", "KUBE_TOKEN"},
+			wantErr:  "ERR: invalid KUBERNETES_EXEC_INFO: ",
+			wantCode: 1,
+		},
+		{
+			name:     "kube-credential with token and client certificate",
+			secrets:  map[string]map[string]string{"keychain": kubeSecrets},
+			args:     []string{"kube-credential", "KUBE_TOKEN", "--client-certificate", "KUBE_CERT", "--client-key", "KUBE_KEY"},
+			wantErr:  "ERR: use either a token key or --client-certificate and --client-key, not both",
+			wantCode: 1,
+		},
+		{
+			name:     "kube-credential without credentials",
+			secrets:  map[string]map[string]string{"keychain": kubeSecrets},
+			args:     []string{"kube-credential"},
+			wantErr:  "ERR: specify a token key or --client-certificate and --client-key",
+			wantCode: 1,
+		},
+		{
+			name:     "kube-credential with client certificate only",
+			secrets:  map[string]map[string]string{"keychain": kubeSecrets},
+			args:     []string{"kube-credential", "--client-certificate", "KUBE_CERT"},
+			wantErr:  "ERR: --client-certificate and --client-key must be used together",
+			wantCode: 1,
+		},
+		{
+			name:     "kube-credential with invalid expiration",
+			secrets:  map[string]map[string]string{"keychain": kubeSecrets},
+			args:     []string{"kube-credential", "KUBE_TOKEN", "--expiration", "KUBE_TOKEN"},
+			wantErr:  "ERR: error getting expiration: KUBE_TOKEN does not hold an RFC 3339 timestamp",
+			wantCode: 1,
+		},
+		{
+			name:     "kube-credential with missing key",
+			secrets:  map[string]map[string]string{"keychain": kubeSecrets},
+			args:     []string{"kube-credential", "MISSING"},
+			wantErr:  "ERR: error getting credentials: MISSING",
+			wantCode: 1,
+		},
+		{
+			name:    "terraform-data with keys and attributes",
+			config:  testConfig,
+			secrets: tfSecrets,
+			stdin:   `{"keys": "API_TOKEN, DB_PASSWORD,", "pat": "API_TOKEN", "API_TOKEN": "API_TOKEN"}`,
+			args:    []string{"terraform-data"},
+			wantOut: `{"API_TOKEN":"tok","DB_PASSWORD":"db","pat":"tok"}` + "\n",
+		},
+		{
+			name:    "terraform-data with empty query",
+			config:  testConfig,
+			secrets: tfSecrets,
+			stdin:   `{}`,
+			args:    []string{"terraform-data"},
+			wantOut: `{"API_TOKEN":"tok","DB_PASSWORD":"db","LOG_LEVEL":"info"}` + "\n",
+		},
+		{
+			name:     "terraform-data with empty query without config",
+			secrets:  tfSecrets,
+			stdin:    `{}`,
+			args:     []string{"terraform-data"},
+			wantErr:  "No config found\n",
+			wantCode: 1,
+		},
+		{
+			name:     "terraform-data with missing key",
+			config:   testConfig,
+			secrets:  tfSecrets,
+			stdin:    `{"keys": "API_TOKEN,MISSING"}`,
+			args:     []string{"terraform-data"},
+			wantErr:  "MISSING: " + backend.ErrNotFound.Error(),
+			wantCode: 1,
+		},
+		{
+			name:     "terraform-data with invalid query",
+			secrets:  tfSecrets,
+			stdin:    `{"keys": ["API_TOKEN"]}`,
+			args:     []string{"terraform-data"},
+			wantErr:  "ERR: invalid query, expected a JSON object of strings",
+			wantCode: 1,
+		},
+		{
+			name:     "terraform-data with conflicting attributes",
+			secrets:  tfSecrets,
+			stdin:    `{"keys": "API_TOKEN", "API_TOKEN": "DB_PASSWORD"}`,
+			args:     []string{"terraform-data"},
+			wantErr:  "ERR: invalid query: result attribute API_TOKEN would hold both API_TOKEN and DB_PASSWORD\n",
+			wantCode: 1,
+		},
+		{
+			name:    "get-env with tf-vars",
+			secrets: tfSecrets,
+			args:    []string{"get-env", "API_TOKEN", "--tf-vars", "--shell", "bash"},
+			wantOut: "export TF_VAR_api_token='tok'\n",
+		},
+		{
+			name:     "get-env with colliding tf-vars",
+			secrets:  map[string]map[string]string{"keychain": {"FOO": "1", "foo": "2"}},
+			args:     []string{"get-env", "FOO,foo", "--tf-vars"},
+			wantErr:  "ERR: cannot use --tf-vars: FOO and foo both map to TF_VAR_foo\n",
+			wantCode: 1,
+		},
+		{
+			name:     "get-env with tf-vars and a missing key",
+			secrets:  tfSecrets,
+			args:     []string{"get-env", "API_TOKEN,MISSING", "--tf-vars"},
+			wantErr:  "MISSING: secret not found",
+			wantCode: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			for provider, secrets := range tt.secrets {
+				h.backends[provider] = backend.NewMemoryBackend(secrets)
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if tt.config != "" {
+				h.writeConfig(tt.config)
+			}
+			if tt.subdir {
+				sub := filepath.Join(h.dir, "sub")
+				if err := os.Mkdir(sub, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				h.dir = sub
+			}
+
+			stdout, stderr, code := h.run(tt.stdin, tt.args...)
+			if code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (stderr: %q)", code, tt.wantCode, stderr)
+			}
+			if !strings.Contains(stdout, tt.wantOut) || (tt.wantOut == "" && stdout != "") {
+				t.Errorf("stdout = %q, want %q", stdout, tt.wantOut)
+			}
+			if !strings.Contains(stderr, tt.wantErr) || (tt.wantErr == "" && stderr != "") {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetRegistersKey(t *testing.T) {
+	h := newHarness(t)
+	h.writeConfig(testConfig)
+
+	stdout, stderr, code := h.run("", "set", "NEW_KEY", "secret", "--backend", "1password")
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	if stdout != "Password set for NEW_KEY\n" {
+		t.Errorf("stdout = %q", stdout)
+	}
+	if got, err := h.backends["1password"].GetPassword("NEW_KEY"); got != "secret" {
+		t.Errorf("stored value = %q, %v, want secret", got, err)
+	}
+
+	cfg, err := config.Load(filepath.Join(h.dir, ".chainenv.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := cfg.FindKey("NEW_KEY")
+	if !ok {
+		t.Fatal("NEW_KEY not registered in config")
+	}
+	if entry.Provider != "1password" {
+		t.Errorf("provider = %q, want 1password", entry.Provider)
+	}
+
+	// Setting an existing key fails, update replaces it.
+	if _, _, code := h.run("", "set", "NEW_KEY", "other", "--backend", "1password"); code != 1 {
+		t.Errorf("set existing key: exit code = %d, want 1", code)
+	}
+	if _, stderr, code := h.run("", "update", "NEW_KEY", "other", "--backend", "1password"); code != 0 {
+		t.Fatalf("update: exit code = %d, stderr: %s", code, stderr)
+	}
+	if got, err := h.backends["1password"].GetPassword("NEW_KEY"); got != "other" {
+		t.Errorf("updated value = %q, %v, want other", got, err)
+	}
+}
+
+func TestGenerateReusesPolicy(t *testing.T) {
+	h := newHarness(t)
+	h.writeConfig(testConfig)
+
+	stdout, stderr, code := h.run("", "generate", "DB_PASSWORD", "--length", "20", "--charset", "lower", "--print")
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	first := strings.TrimSuffix(stdout, "\n")
+	if len(first) != 20 || strings.Trim(first, "abcdefghijklmnopqrstuvwxyz") != "" {
+		t.Fatalf("generated %q, want 20 lowercase letters", first)
+	}
+
+	cfg, err := config.Load(filepath.Join(h.dir, ".chainenv.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := cfg.FindKey("DB_PASSWORD")
+	if !ok || entry.Generate == nil || entry.Generate.Length != 20 || entry.Generate.Charset != "lower" {
+		t.Fatalf("stored entry = %+v, want the generation policy", entry)
+	}
+	// The value goes to the provider configured for the key.
+	if got, err := h.backends["1password"].GetPassword("DB_PASSWORD"); got != first {
+		t.Fatalf("stored value = %q, %v, want %q", got, err, first)
+	}
+
+	// Regenerating without flags follows the stored policy.
+	if _, stderr, code := h.run("", "generate", "DB_PASSWORD", "--update"); code != 0 {
+		t.Fatalf("generate --update: exit code = %d, stderr: %s", code, stderr)
+	}
+	second, err := h.backends["1password"].GetPassword("DB_PASSWORD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || len(second) != 20 || strings.Trim(second, "abcdefghijklmnopqrstuvwxyz") != "" {
+		t.Errorf("regenerated %q, want a new value of 20 lowercase letters", second)
+	}
+}
+
+func TestSetCreatesConfig(t *testing.T) {
+	h := newHarness(t)
+
+	if _, stderr, code := h.run("", "set", "API_TOKEN", "tok"); code != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	cfg, err := config.Load(config.DefaultConfigPath(h.dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range cfg.Keys {
+		names = append(names, entry.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "API_TOKEN" {
+		t.Errorf("keys = %v, want [API_TOKEN]", names)
+	}
+}
+
+func TestGetEnvCI(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		wantCode int
+		wantErr  string
+		// wantShell is the expected output if no CI output is written.
+		wantShell string
+	}{
+		{name: "explicit provider", args: []string{"get-env", "--ci", "github"}},
+		{name: "explicit provider with equals", args: []string{"get-env", "--ci=github"}},
+		{name: "explicit provider with keys", args: []string{"get-env", "API_TOKEN", "--ci", "github"}},
+		{name: "auto", args: []string{"get-env", "--ci", "auto"}, env: map[string]string{"GITHUB_ACTIONS": "true"}},
+		{name: "auto outside CI", args: []string{"get-env", "--ci", "auto"}, wantCode: 1, wantErr: "ERR: no supported CI environment detected"},
+		{name: "detected", args: []string{"get-env"}, env: map[string]string{"GITHUB_ACTIONS": "true"}},
+		{name: "not detected", args: []string{"get-env"}, wantShell: "API_TOKEN='token-value'\n"},
+		{name: "detection skipped for shell format", args: []string{"get-env", "--shell", "bash"}, env: map[string]string{"GITHUB_ACTIONS": "true"}, wantShell: "export API_TOKEN='token-value'\n"},
+		{name: "detection skipped for legacy shell flag", args: []string{"get-env", "--fish"}, env: map[string]string{"GITHUB_ACTIONS": "true"}, wantShell: "set -x API_TOKEN 'token-value'\n"},
+		{name: "none", args: []string{"get-env", "--ci", "none"}, env: map[string]string{"GITHUB_ACTIONS": "true"}, wantShell: "API_TOKEN='token-value'\n"},
+		{name: "unknown provider", args: []string{"get-env", "--ci", "jenkins"}, wantCode: 1, wantErr: "ERR: unknown CI provider: jenkins"},
+		{name: "missing value", args: []string{"get-env", "--ci"}, wantCode: 1, wantErr: "ERR: flag needs an argument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			h.writeConfig(`
+[[keys]]
+name = "API_TOKEN"
+`)
+			if err := h.backends["keychain"].SetPassword("API_TOKEN", "token-value", false); err != nil {
+				t.Fatal(err)
+			}
+			envFile := filepath.Join(t.TempDir(), "github_env")
+			t.Setenv("GITHUB_ENV", envFile)
+			for _, name := range []string{"GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			stdout, stderr, code := h.run("", tt.args...)
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d, stderr: %s", code, tt.wantCode, stderr)
+			}
+			if tt.wantErr != "" {
+				if !strings.HasPrefix(stderr, tt.wantErr) {
+					t.Errorf("stderr = %q, want prefix %q", stderr, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantShell != "" {
+				if stdout != tt.wantShell {
+					t.Errorf("stdout = %q, want %q", stdout, tt.wantShell)
+				}
+				if _, err := os.Stat(envFile); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("GITHUB_ENV written without CI output: %v", err)
+				}
+				return
+			}
+
+			if stdout != "::add-mask::token-value\n" {
+				t.Errorf("stdout = %q, want only the mask", stdout)
+			}
+			data, err := os.ReadFile(envFile)
+			if err != nil {
+				t.Fatalf("GITHUB_ENV not written: %v", err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if len(lines) != 3 || !strings.HasPrefix(lines[0], "API_TOKEN<<") || lines[1] != "token-value" {
+				t.Errorf("GITHUB_ENV = %q, want API_TOKEN", data)
+			}
+		})
+	}
+}
+
+func TestAuditLogDateBounds(t *testing.T) {
+	h := newHarness(t)
+	path := filepath.Join(h.dir, "audit.jsonl")
+	auditLog := audit.Open(path)
+	for _, e := range []struct {
+		key  string
+		time time.Time
+	}{
+		{"BEFORE", time.Date(2025, 1, 30, 23, 59, 0, 0, time.Local)},
+		{"START", time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local)},
+		{"END", time.Date(2025, 1, 31, 23, 59, 59, 0, time.Local)},
+		{"AFTER", time.Date(2025, 2, 1, 0, 0, 0, 0, time.Local)},
+	} {
+		if err := auditLog.Append(audit.Entry{Time: e.time, Op: audit.OpGet, Key: e.key, Provider: "keychain", Outcome: audit.OutcomeOK}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stdout, stderr, code := h.run("", "audit", "log", "--file", path, "--since", "2025-01-31", "--until", "2025-01-31", "--json")
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	var keys []string
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		var e audit.Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid entry %q: %v", line, err)
+		}
+		keys = append(keys, e.Key)
+	}
+	if want := []string{"START", "END"}; !slices.Equal(keys, want) {
+		t.Errorf("entries = %v, want %v", keys, want)
+	}
+}
+
+func TestAuditSecretsReferencedKeys(t *testing.T) {
+	h := newHarness(t)
+	h.writeConfig(`
+[[keys]]
+name = "API_TOKEN"
+
+[[git_credentials]]
+host = "github.com"
+key = "GITHUB_PASSWORD"
+
+["1password"]
+service_account_token_key = "OP_TOKEN"
+`)
+	dockerKey := dockercred.Key("https://ghcr.io")
+	for key, value := range map[string]string{
+		"API_TOKEN":       "q8R#vX2m!Lp9zK4w",
+		"GITHUB_PASSWORD": "N3j$bT7y@Hc5rF1e",
+		"OP_TOKEN":        "W6u%Gd8s^Ma2xQ0k",
+		dockerKey:         "Z4p&Jn9v*Ye3tB7c",
+		"ORPHAN":          "E5h(Ks1q)Ur8wD6m",
+	} {
+		if err := h.backends["keychain"].SetPassword(key, value, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stdout, stderr, code := h.run("", "audit", "secrets", "--json")
+	if code != 1 {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	var findings []hygiene.Finding
+	if err := json.Unmarshal([]byte(stdout), &findings); err != nil {
+		t.Fatalf("invalid output %q: %v", stdout, err)
+	}
+	want := []hygiene.Finding{{Key: "ORPHAN", Check: hygiene.CheckUnreferenced, Detail: "not referenced by any known config"}}
+	if !slices.Equal(findings, want) {
+		t.Errorf("findings = %+v, want %+v", findings, want)
+	}
+}
+
+func TestGitCredentialErase(t *testing.T) {
+	tests := []struct {
+		name      string
+		password  string
+		wantValue string
+		wantGone  bool
+	}{
+		{name: "matching password", password: "old-token", wantGone: true},
+		{name: "different password", password: "rejected-token", wantValue: "old-token"},
+		{name: "no password", wantGone: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			h.writeConfig(`
+[[git_credentials]]
+host = "git.example.com"
+key = "GIT_TOKEN"
+`)
+			if err := h.backends["keychain"].SetPassword("GIT_TOKEN", "old-token", false); err != nil {
+				t.Fatal(err)
+			}
+
+			stdin := "protocol=https\nhost=git.example.com\nusername=me\n"
+			if tt.password != "" {
+				stdin += "password=" + tt.password + "\n"
+			}
+			if _, stderr, code := h.run(stdin, "git-credential", "erase"); code != 0 {
+				t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+			}
+
+			value, err := h.backends["keychain"].GetPassword("GIT_TOKEN")
+			if tt.wantGone {
+				if !errors.Is(err, backend.ErrNotFound) {
+					t.Errorf("GIT_TOKEN = %q, %v, want it erased", value, err)
+				}
+			} else if err != nil || value != tt.wantValue {
+				t.Errorf("GIT_TOKEN = %q, %v, want %q", value, err, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestExecExitCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		redact bool
+		want   int
+	}{
+		{name: "success", script: "exit 0", want: 0},
+		{name: "failure", script: "exit 3", want: 3},
+		{name: "sigterm", script: "kill -TERM $$", want: 128 + int(syscall.SIGTERM)},
+		{name: "sigint", script: "kill -INT $$", want: 128 + int(syscall.SIGINT)},
+		{name: "sigterm redacted", script: "kill -TERM $$", redact: true, want: 128 + int(syscall.SIGTERM)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			if err := h.backends["keychain"].SetPassword("API_TOKEN", "token-value", false); err != nil {
+				t.Fatal(err)
+			}
+			args := []string{"exec", "--keys", "API_TOKEN"}
+			if tt.redact {
+				args = append(args, "--redact")
+			}
+			args = append(args, "--", "sh", "-c", tt.script)
+			if _, stderr, code := h.run("", args...); code != tt.want {
+				t.Errorf("exit code = %d, want %d, stderr: %s", code, tt.want, stderr)
+			}
+		})
+	}
+}
+
+// failingUpdate fails updates of account, as a flaky backend would.
+type failingUpdate struct {
+	*backend.MemoryBackend
+	account string
+}
+
+func (f failingUpdate) SetPassword(account, password string, update bool) error {
+	if update && account == f.account {
+		return errors.New("connection reset")
+	}
+	return f.MemoryBackend.SetPassword(account, password, update)
+}
+
+func TestRotate(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		args      []string
+		wantCode  int
+		wantErr   string
+		wantValue string
+		// wantPrevious is the value kept as API_TOKEN.previous, if any.
+		wantPrevious string
+		// wantHook maps files the hook writes to $HOOK_OUT to their content.
+		wantHook map[string]string
+	}{
+		{
+			name:         "without hook",
+			args:         []string{"rotate", "API_TOKEN", "--prompt"},
+			wantValue:    "new-value",
+			wantPrevious: "old-value",
+		},
+		{
+			name: "hook reading stdin",
+			args: []string{
+				"rotate", "API_TOKEN", "--prompt",
+				"--hook", `cat > "$HOOK_OUT/stdin"; printf '%s %s' "$CHAINENV_KEY" "$CHAINENV_PROVIDER" > "$HOOK_OUT/env"`,
+			},
+			wantValue:    "new-value",
+			wantPrevious: "old-value",
+			wantHook:     map[string]string{"stdin": "new-value\n", "env": "API_TOKEN keychain"},
+		},
+		{
+			name: "configured hook reading env",
+			config: `
+[[keys]]
+name = "API_TOKEN"
+
+[keys.rotate]
+command = 'printf "%s %s" "$CHAINENV_NEW_VALUE" "$CHAINENV_OLD_VALUE" > "$HOOK_OUT/env"'
+input = "env"
+`,
+			args:         []string{"rotate", "API_TOKEN", "--prompt"},
+			wantValue:    "new-value",
+			wantPrevious: "old-value",
+			wantHook:     map[string]string{"env": "new-value old-value"},
+		},
+		{
+			name:      "failing hook",
+			args:      []string{"rotate", "API_TOKEN", "--prompt", "--hook", "exit 3"},
+			wantCode:  1,
+			wantErr:   "ERR: rotation hook failed, password not changed: exit status 3\n",
+			wantValue: "old-value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			if tt.config != "" {
+				h.writeConfig(tt.config)
+			}
+			hookOut := t.TempDir()
+			t.Setenv("HOOK_OUT", hookOut)
+			keychain := h.backends["keychain"]
+			if err := keychain.SetPassword("API_TOKEN", "old-value", false); err != nil {
+				t.Fatal(err)
+			}
+
+			stdout, stderr, code := h.run("new-value\n", tt.args...)
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d, stderr: %s", code, tt.wantCode, stderr)
+			}
+			if tt.wantErr != "" && stderr != tt.wantErr {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantErr)
+			}
+			if tt.wantCode == 0 && !strings.HasPrefix(stdout, "Password rotated for API_TOKEN.") {
+				t.Errorf("stdout = %q", stdout)
+			}
+
+			if v, err := keychain.GetPassword("API_TOKEN"); err != nil || v != tt.wantValue {
+				t.Errorf("value = %q, %v, want %q", v, err, tt.wantValue)
+			}
+			previous, err := keychain.GetPassword("API_TOKEN" + previousSuffix)
+			switch {
+			case tt.wantPrevious == "" && !errors.Is(err, backend.ErrNotFound):
+				t.Errorf("previous value = %q, %v, want none", previous, err)
+			case tt.wantPrevious != "" && previous != tt.wantPrevious:
+				t.Errorf("previous value = %q, %v, want %q", previous, err, tt.wantPrevious)
+			}
+
+			for name, want := range tt.wantHook {
+				got, err := os.ReadFile(filepath.Join(hookOut, name))
+				if err != nil || string(got) != want {
+					t.Errorf("hook wrote %s = %q, %v, want %q", name, got, err, want)
+				}
+			}
+			if tt.wantHook == nil {
+				if entries, _ := os.ReadDir(hookOut); len(entries) != 0 {
+					t.Errorf("hook output %v, want none", entries)
+				}
+			}
+		})
+	}
+}
+
+func TestRotateFinalize(t *testing.T) {
+	h := newHarness(t)
+	keychain := h.backends["keychain"]
+	if err := keychain.SetPassword("API_TOKEN", "old-value", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, stderr, code := h.run("new-value\n", "rotate", "API_TOKEN", "--prompt"); code != 0 {
+		t.Fatalf("rotate = %d, stderr: %s", code, stderr)
+	}
+	// Rotating again before finalizing would lose the previous value.
+	_, stderr, code := h.run("newer-value\n", "rotate", "API_TOKEN", "--prompt")
+	if code != 1 || !strings.Contains(stderr, "a previous rotation of API_TOKEN has not been finalized") {
+		t.Errorf("second rotate = %d, stderr %q", code, stderr)
+	}
+
+	stdout, stderr, code := h.run("", "rotate", "API_TOKEN", "--finalize")
+	if code != 0 || stdout != "Rotation finalized for API_TOKEN\n" {
+		t.Fatalf("finalize = %d, %q, stderr: %s", code, stdout, stderr)
+	}
+	if _, err := keychain.GetPassword("API_TOKEN" + previousSuffix); !errors.Is(err, backend.ErrNotFound) {
+		t.Errorf("previous value after finalize: %v", err)
+	}
+	if v, _ := keychain.GetPassword("API_TOKEN"); v != "new-value" {
+		t.Errorf("value after finalize = %q, want new-value", v)
+	}
+
+	_, stderr, code = h.run("", "rotate", "API_TOKEN", "--finalize")
+	if code != 1 || stderr != "ERR: no rotation in progress for API_TOKEN\n" {
+		t.Errorf("finalize without rotation = %d, stderr %q", code, stderr)
+	}
+}
+
+func TestRotateFailedUpdateCanBeRetried(t *testing.T) {
+	h := newHarness(t)
+	keychain := h.backends["keychain"]
+	if err := keychain.SetPassword("API_TOKEN", "old-value", false); err != nil {
+		t.Fatal(err)
+	}
+	failing := true
+	backendFactory = func(provider, vault string, _ *config.Config) (backend.Backend, error) {
+		if failing {
+			return failingUpdate{keychain, "API_TOKEN"}, nil
+		}
+		return keychain, nil
+	}
+
+	_, stderr, code := h.run("", "rotate", "API_TOKEN")
+	if code != 1 || !strings.Contains(stderr, "failed to store new password") {
+		t.Fatalf("rotate with failing update = %d, stderr %q", code, stderr)
+	}
+	if _, err := keychain.GetPassword("API_TOKEN" + previousSuffix); !errors.Is(err, backend.ErrNotFound) {
+		t.Fatalf("previous value left behind after failed rotation: %v", err)
+	}
+
+	failing = false
+	if _, stderr, code := h.run("", "rotate", "API_TOKEN"); code != 0 {
+		t.Fatalf("retrying rotate = %d, stderr %q", code, stderr)
+	}
+	if v, _ := keychain.GetPassword("API_TOKEN"); v == "old-value" {
+		t.Error("password not rotated")
+	}
+	if v, _ := keychain.GetPassword("API_TOKEN" + previousSuffix); v != "old-value" {
+		t.Errorf("previous value = %q, want old-value", v)
+	}
+}
+
+func TestComputeSyncPlan(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   []string
+		source map[string]string
+		target map[string]string
+		want   []syncStep
+	}{
+		{
+			name:   "create",
+			keys:   []string{"A"},
+			source: map[string]string{"A": "1"},
+			want:   []syncStep{{Key: "A", Action: syncCreate, value: "1"}},
+		},
+		{
+			name:   "update",
+			keys:   []string{"A"},
+			source: map[string]string{"A": "1"},
+			target: map[string]string{"A": "old"},
+			want:   []syncStep{{Key: "A", Action: syncUpdate, value: "1"}},
+		},
+		{
+			name:   "unchanged",
+			keys:   []string{"A"},
+			source: map[string]string{"A": "1"},
+			target: map[string]string{"A": "1"},
+			want:   []syncStep{{Key: "A", Action: syncUnchanged, value: "1"}},
+		},
+		{
+			name:   "missing in source",
+			keys:   []string{"A"},
+			target: map[string]string{"A": "1"},
+			want:   []syncStep{{Key: "A", Action: syncMissing}},
+		},
+		{
+			name:   "sorted and deduplicated",
+			keys:   []string{"C", "A", "B", "A"},
+			source: map[string]string{"A": "1", "B": "2"},
+			target: map[string]string{"B": "2"},
+			want: []syncStep{
+				{Key: "A", Action: syncCreate, value: "1"},
+				{Key: "B", Action: syncUnchanged, value: "2"},
+				{Key: "C", Action: syncMissing},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := computeSyncPlan(tt.keys, tt.source, tt.target); !slices.Equal(got, tt.want) {
+				t.Errorf("plan = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	source := map[string]string{"A": "1", "B": "2", "C": "3"}
+	target := map[string]string{"B": "old", "C": "3", "D": "4"}
+	const syncConfig = `
+[[keys]]
+name = "A"
+
+[[keys]]
+name = "B"
+
+[[keys]]
+name = "C"
+
+[[keys]]
+name = "D"
+`
+	const plan = "+ A (create)\n~ B (update)\n= C (unchanged)\n! D (missing-in-source)\n"
+
+	tests := []struct {
+		name       string
+		args       []string
+		failUpdate string
+		wantOut    string
+		wantErr    string
+		wantCode   int
+		wantTarget map[string]string
+	}{
+		{
+			name:       "dry run",
+			args:       []string{"--dry-run"},
+			wantOut:    plan,
+			wantTarget: target,
+		},
+		{
+			name:       "apply",
+			wantOut:    plan + "Synced 1password to keychain: 1 created, 1 updated, 1 unchanged, 1 missing in source, 0 failed\n",
+			wantTarget: map[string]string{"A": "1", "B": "2", "C": "3", "D": "4"},
+		},
+		{
+			name:       "failing key",
+			failUpdate: "B",
+			wantOut:    plan + "Synced 1password to keychain: 1 created, 0 updated, 1 unchanged, 1 missing in source, 1 failed\n",
+			wantErr:    "ERR: Failed to update B: connection reset\n",
+			wantCode:   1,
+			wantTarget: map[string]string{"A": "1", "B": "old", "C": "3", "D": "4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			h.writeConfig(syncConfig)
+			h.backends["1password"] = backend.NewMemoryBackend(source)
+			keychain := backend.NewMemoryBackend(target)
+			h.backends["keychain"] = keychain
+			if tt.failUpdate != "" {
+				backendFactory = func(provider, vault string, _ *config.Config) (backend.Backend, error) {
+					if provider == "keychain" {
+						return failingUpdate{keychain, tt.failUpdate}, nil
+					}
+					return h.backends[provider], nil
+				}
+			}
+
+			args := append([]string{"sync", "--from", "1password", "--to", "keychain"}, tt.args...)
+			stdout, stderr, code := h.run("", args...)
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d, stderr: %s", code, tt.wantCode, stderr)
+			}
+			if stdout != tt.wantOut {
+				t.Errorf("stdout = %q, want %q", stdout, tt.wantOut)
+			}
+			if stderr != tt.wantErr {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantErr)
+			}
+
+			for key, want := range tt.wantTarget {
+				if got, err := keychain.GetPassword(key); err != nil || got != want {
+					t.Errorf("target %s = %q, %v, want %q", key, got, err, want)
+				}
+			}
+			if accounts, _ := keychain.List(); len(accounts) != len(tt.wantTarget) {
+				t.Errorf("target accounts = %v, want %d", accounts, len(tt.wantTarget))
+			}
+		})
+	}
+}
+
+// failingBatch fails every batch lookup, like op inject does on values it
+// can't template.
+type failingBatch struct {
+	*backend.MemoryBackend
+}
+
+func (failingBatch) GetMultiplePasswords([]string) (map[string]string, error) {
+	return nil, errors.New("batch lookup failed")
+}
+
+func TestReadPasswordsFallsBackToSingleLookups(t *testing.T) {
+	newHarness(t)
+	b := failingBatch{backend.NewMemoryBackend(map[string]string{"A": "1", "B": "2"})}
+
+	values, err := readPasswords(b, []string{"A", "B", "MISSING"})
+	if err != nil {
+		t.Fatalf("readPasswords: %v", err)
+	}
+	if len(values) != 2 || values["A"] != "1" || values["B"] != "2" {
+		t.Errorf("values = %v, want A and B", values)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	h := newHarness(t)
+	if err := h.backends["keychain"].SetPassword("API_TOKEN", "token-value", false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Diagnostics never end up on stdout, where they'd corrupt the value.
+	stdout, stderr, code := h.run("", "get", "API_TOKEN", "--debug")
+	if code != 0 || stdout != "token-value\n" {
+		t.Fatalf("get --debug = %q, %d, stderr: %s", stdout, code, stderr)
+	}
+	if !strings.Contains(stderr, "DEBUG: Getting password for account: API_TOKEN") {
+		t.Errorf("stderr = %q, want debug messages", stderr)
+	}
+
+	_, stderr, _ = h.run("", "get", "API_TOKEN", "--log-level", "debug", "--log-format", "json")
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	for _, line := range lines {
+		var record struct{ Level, Msg string }
+		if err := json.Unmarshal([]byte(line), &record); err != nil || record.Level != "DEBUG" {
+			t.Errorf("stderr line %q isn't a JSON debug record: %v", line, err)
+		}
+	}
+
+	_, stderr, _ = h.run("", "get", "API_TOKEN", "--log-level", "warn", "--debug=false")
+	if stderr != "" {
+		t.Errorf("stderr at warn level = %q, want nothing", stderr)
+	}
+
+	for _, args := range [][]string{{"--log-level", "loud"}, {"--log-format", "xml"}} {
+		_, stderr, code := h.run("", append([]string{"get", "API_TOKEN"}, args...)...)
+		if code != 1 || !strings.HasPrefix(stderr, "ERR: unknown log") {
+			t.Errorf("get %v = %d, stderr %q, want an unknown log setting error", args, code, stderr)
+		}
+	}
+}
+
+func TestAgentUsesClientTokenKey(t *testing.T) {
+	h := newHarness(t)
+	h.backends["1password"] = backend.NewMemoryBackend(map[string]string{"DB_PASSWORD": "db-value"})
+	var tokenKeys []string
+	backendFactory = func(provider, vault string, cfg *config.Config) (backend.Backend, error) {
+		tokenKeys = append(tokenKeys, cfg.OnePassword.ServiceAccountTokenKey)
+		return h.backends[provider], nil
+	}
+
+	socket := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	l, err := agent.Listen(socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go agent.NewServer(newAgentFactory(), 0, 0).Serve(l)
+	t.Setenv(agent.SockEnv, socket)
+
+	h.writeConfig("[\"1password\"]\nservice_account_token_key = \"OP_TOKEN_A\"\n")
+	stdout, stderr, code := h.run("", "get", "--backend", "1password", "DB_PASSWORD")
+	if code != 0 || stdout != "db-value\n" {
+		t.Fatalf("get through agent: code %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+	if !slices.Equal(tokenKeys, []string{"OP_TOKEN_A"}) {
+		t.Fatalf("agent created backends with token keys %q, want the client's", tokenKeys)
+	}
+
+	h.writeConfig("[\"1password\"]\nservice_account_token_key = \"OP_TOKEN_B\"\n")
+	_, stderr, code = h.run("", "get", "--backend", "1password", "DB_PASSWORD")
+	if code != 1 || !strings.Contains(stderr, `not "OP_TOKEN_B"; start a separate agent`) {
+		t.Fatalf("expected a different token key to be refused, got code %d, stderr %q", code, stderr)
+	}
+	if len(tokenKeys) != 1 {
+		t.Fatalf("agent created a backend for another account: %q", tokenKeys)
+	}
+}
@@ -2,70 +2,94 @@ package cmd
 
 import (
 	"fmt"
-	"os"
+	"time"
 
-	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/chainenv"
 	"github.com/dvcrn/chainenv/config"
+	"github.com/dvcrn/chainenv/secretfile"
 )
 
 func loadConfig() (*config.Config, error) {
-	cwd, err := os.Getwd()
+	cwd, err := getwd()
 	if err != nil {
 		return nil, err
 	}
+	return chainenv.FindConfig(cwd)
+}
+
+// loadConfigForWrite returns the config that commands registering keys should
+// modify, along with its path. If no config exists yet, the default path in
+// the current directory is used and an empty config is returned.
+func loadConfigForWrite() (string, *config.Config, error) {
+	cwd, err := getwd()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to determine current directory: %w", err)
+	}
 
 	configPath, ok, err := config.FindConfig(cwd)
 	if err != nil {
-		return nil, err
+		return "", nil, fmt.Errorf("failed to locate config file: %w", err)
 	}
 	if !ok {
-		return nil, nil
+		configPath = config.DefaultConfigPath(cwd)
 	}
 
-	return config.Load(configPath)
-}
-
-func resolveKeyConfig(cfg *config.Config, name, fallbackProvider string) (string, *string) {
-	provider := fallbackProvider
-	var defaultValue *string
-	if cfg == nil {
-		return provider, nil
+	cfg, err := config.LoadOrEmpty(configPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
-	if entry, ok := cfg.FindKey(name); ok {
-		if entry.Provider != "" {
-			provider = entry.Provider
-		}
-		defaultValue = entry.Default
-	}
+	return configPath, cfg, nil
+}
 
-	return provider, defaultValue
+// newResolver returns a resolver for cfg that looks up keys without a
+// configured provider in the backend selected with --backend.
+func newResolver(cfg *config.Config) *chainenv.Resolver {
+	return chainenv.NewResolver(cfg, backendType, getBackendWithType)
 }
 
-func ensureOpServiceAccountToken(cfg *config.Config) error {
-	if os.Getenv("OP_SERVICE_ACCOUNT_TOKEN") != "" {
-		return nil
-	}
-	if cfg == nil || cfg.OnePassword == nil {
-		return nil
-	}
-	tokenKey := cfg.OnePassword.ServiceAccountTokenKey
-	if tokenKey == "" {
-		return nil
+// resolveExpiration resolves key and parses its value as an RFC 3339
+// timestamp.
+func resolveExpiration(resolver *chainenv.Resolver, key string) (time.Time, error) {
+	value, _, err := resolver.Resolve(key)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", key, err)
 	}
-
-	keychain, err := backend.NewKeychainBackend()
+	expiration, err := time.Parse(time.RFC3339, value)
 	if err != nil {
-		return fmt.Errorf("keychain backend unavailable: %w", err)
+		return time.Time{}, fmt.Errorf("%s does not hold an RFC 3339 timestamp: %w", key, err)
 	}
+	return expiration, nil
+}
 
-	token, err := keychain.GetPassword(tokenKey)
-	if err != nil {
-		return fmt.Errorf("failed to load %s from keychain: %w", tokenKey, err)
+// writeSecretFiles replaces the value of every key configured with file = true
+// with the path of a private file holding that value. The returned session is
+// nil if no file was written.
+func writeSecretFiles(cfg *config.Config, values map[string]string) (*secretfile.Session, error) {
+	if cfg == nil {
+		return nil, nil
 	}
 
-	if err := os.Setenv("OP_SERVICE_ACCOUNT_TOKEN", token); err != nil {
-		return fmt.Errorf("failed to set OP_SERVICE_ACCOUNT_TOKEN: %w", err)
+	var session *secretfile.Session
+	for _, entry := range cfg.Keys {
+		value, ok := values[entry.Name]
+		if !entry.File || !ok {
+			continue
+		}
+
+		if session == nil {
+			var err error
+			if session, err = secretfile.NewSession(); err != nil {
+				return nil, err
+			}
+		}
+
+		path, err := session.Write(entry.Name, value)
+		if err != nil {
+			session.Remove()
+			return nil, err
+		}
+		values[entry.Name] = path
 	}
-	return nil
+	return session, nil
 }
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dvcrn/chainenv/config"
+	"github.com/spf13/cobra"
+)
+
+var lintJSON bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the project config",
+}
+
+var configLintCmd = &cobra.Command{
+	Use:   "lint [path]",
+	Short: "Check the config for mistakes",
+	Long: `Check .chainenv.toml or chainenv.toml for syntax errors, unknown fields, empty or duplicate key
+names, names that aren't valid environment variables, unknown providers and invalid policies.
+Exits with a non-zero status if any issue is found.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var configPath string
+		if len(args) > 0 {
+			configPath = args[0]
+		} else {
+			cwd, err := getwd()
+			if err != nil {
+				return fmt.Errorf("failed to determine current directory: %w", err)
+			}
+			path, ok, err := config.FindConfig(cwd)
+			if err != nil {
+				return fmt.Errorf("failed to locate config file: %w", err)
+			}
+			if !ok {
+				return errors.New("no config found")
+			}
+			configPath = path
+		}
+
+		issues, err := config.LintFile(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to read config: %w", err)
+		}
+
+		out := cmd.OutOrStdout()
+		if lintJSON {
+			if issues == nil {
+				issues = []config.Issue{}
+			}
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(issues); err != nil {
+				return fmt.Errorf("error encoding issues: %w", err)
+			}
+		} else {
+			for _, issue := range issues {
+				if issue.Line > 0 {
+					fmt.Fprintf(out, "%s:%d: %s\n", configPath, issue.Line, issue.Message)
+				} else {
+					fmt.Fprintf(out, "%s: %s\n", configPath, issue.Message)
+				}
+			}
+		}
+
+		if len(issues) > 0 {
+			return exitCode(1)
+		}
+		return nil
+	},
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the config file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := cmd.OutOrStdout().Write(config.Schema)
+		return err
+	},
+}
+
+func init() {
+	configLintCmd.Flags().BoolVar(&lintJSON, "json", false, "Output issues as JSON")
+	configCmd.AddCommand(configLintCmd)
+	configCmd.AddCommand(configSchemaCmd)
+	rootCmd.AddCommand(configCmd)
+}
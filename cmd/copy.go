@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -20,17 +19,15 @@ var copyCmd = &cobra.Command{
 	Short:   "Copy passwords between backends",
 	Long:    `Copy specified passwords from one backend to another (keychain <-> 1password)`,
 	Args:    cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		sourceBackend, err := getBackendWithType(source)
 		if err != nil {
-			log.Err("Error initializing source backend: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("error initializing source backend: %w", err)
 		}
 
 		targetBackend, err := getBackendWithType(target)
 		if err != nil {
-			log.Err("Error initializing target backend: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("error initializing target backend: %w", err)
 		}
 
 		keys := []string{}
@@ -42,22 +39,40 @@ var copyCmd = &cobra.Command{
 			}
 		}
 
-		passwords, _ := sourceBackend.GetMultiplePasswords(keys)
+		passwords, err := readPasswords(sourceBackend, keys)
+		if err != nil {
+			return fmt.Errorf("error reading from %s: %w", source, err)
+		}
+
+		failed := false
+		for _, key := range keys {
+			password, ok := passwords[key]
+			if !ok {
+				log.Err("Password for %s not found in %s", key, source)
+				failed = true
+				continue
+			}
 
-		for key, password := range passwords {
 			if err := targetBackend.SetPassword(key, password, false); err != nil {
-				if overwrite {
-					if err := targetBackend.SetPassword(key, password, true); err != nil {
-						log.Err("Failed to overwrite password for %s: %v", key, err)
-						continue
-					}
-				} else {
+				if !overwrite {
 					log.Err("Failed to copy password for %s: %v. Use --overwrite to overwrite existing items.", key, err)
+					failed = true
+					continue
+				}
+				if err := targetBackend.SetPassword(key, password, true); err != nil {
+					log.Err("Failed to overwrite password for %s: %v", key, err)
+					failed = true
+					continue
 				}
 			}
 
-			fmt.Printf("Copied password for %s from %s to %s\n", key, source, target)
+			fmt.Fprintf(cmd.OutOrStdout(), "Copied password for %s from %s to %s\n", key, source, target)
+		}
+
+		if failed {
+			return exitCode(1)
 		}
+		return nil
 	},
 }
 
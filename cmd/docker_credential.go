@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dvcrn/chainenv/dockercred"
+	"github.com/spf13/cobra"
+)
+
+// dockerCredentialBinary is the name Docker runs for credsStore "chainenv".
+// When invoked under this name, chainenv runs the docker-credential command.
+const dockerCredentialBinary = "docker-credential-chainenv"
+
+// Docker runs credential helpers without arguments beyond the action, so the
+// backend and vault can also be set through the environment.
+const (
+	dockerBackendEnv = "CHAINENV_DOCKER_BACKEND"
+	dockerVaultEnv   = "CHAINENV_DOCKER_VAULT"
+)
+
+var dockerCredentialCmd = &cobra.Command{
+	Use:   "docker-credential <get|store|erase|list>",
+	Short: "Store Docker registry credentials in the backend",
+	Long: `Act as a Docker credential helper, storing registry credentials in the backend instead of
+~/.docker/config.json. Link or copy chainenv as ` + dockerCredentialBinary + ` somewhere on PATH and set
+"credsStore": "chainenv" in ~/.docker/config.json, e.g.:
+  ln -s "$(command -v chainenv)" /usr/local/bin/` + dockerCredentialBinary + `
+
+Credentials are stored under the key ` + dockercred.KeyPrefix + `<base64url-encoded server URL>. Since Docker doesn't pass
+flags to helpers, the backend and vault can be set with ` + dockerBackendEnv + ` and ` + dockerVaultEnv + `.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"get", "store", "erase", "list"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		provider := backendType
+		if env := os.Getenv(dockerBackendEnv); env != "" && !cmd.Flags().Changed("backend") {
+			provider = env
+		}
+		if env := os.Getenv(dockerVaultEnv); env != "" && !cmd.Flags().Changed("vault") {
+			opVault = env
+		}
+
+		b, err := getBackendWithType(provider)
+		if err != nil {
+			return fmt.Errorf("error initializing backend: %w", err)
+		}
+
+		// Docker reads errors from stdout and recognizes missing credentials
+		// by the message alone.
+		if err := dockercred.Serve(b, args[0], cmd.InOrStdin(), cmd.OutOrStdout()); err != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), err)
+			return exitCode(1)
+		}
+		return nil
+	},
+}
+
+// invokedAsDockerCredentialHelper reports whether the binary was run under
+// the name of the Docker credential helper.
+func invokedAsDockerCredentialHelper() bool {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	return name == dockerCredentialBinary
+}
+
+func init() {
+	rootCmd.AddCommand(dockerCredentialCmd)
+}
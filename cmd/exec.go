@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/dvcrn/chainenv/chainenv"
+	"github.com/dvcrn/chainenv/redact"
+	"github.com/dvcrn/chainenv/ref"
+	"github.com/spf13/cobra"
+)
+
+var (
+	execKeys   []string
+	execRedact bool
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec -- command [args...]",
+	Short: "Run a command with secrets in its environment",
+	Long: `Run a command with the keys declared in config (or --keys) exported as environment variables.
+Environment variables whose value contains chainenv://[provider/]KEY references are resolved as well,
+so secrets only ever exist in the child process' environment, e.g.:
+  chainenv exec -- npm publish
+  DATABASE_URL=chainenv://keychain/db-url chainenv exec -- ./server
+
+Keys configured with file = true are written to private 0600 files that are removed when the
+command exits, and the variable holds the file's path instead of the value.
+
+With --redact, the command's stdout and stderr are piped through a filter that replaces every
+resolved secret value, as well as its base64 and URL encodings, with ***.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+
+		resolver := newResolver(cfg)
+		var secrets []string
+		resolve := func(r ref.Reference) (string, error) {
+			value, err := resolver.ResolveReference(r)
+			if err == nil {
+				secrets = append(secrets, value)
+			}
+			return value, err
+		}
+
+		env := os.Environ()
+		for i, kv := range env {
+			key, value, _ := strings.Cut(kv, "=")
+			if !ref.Contains(value) {
+				continue
+			}
+			resolved, err := ref.Replace(value, resolve)
+			if err != nil {
+				return fmt.Errorf("error resolving %s: %w", key, err)
+			}
+			env[i] = key + "=" + resolved
+		}
+
+		keys := execKeys
+		if len(keys) == 0 {
+			keys = chainenv.KeyNames(cfg)
+		}
+		log.Debug("Running %s with keys: %s", args[0], strings.Join(keys, ", "))
+
+		values := make(map[string]string)
+		for _, key := range keys {
+			value, _, err := resolver.Resolve(key)
+			if err != nil {
+				return fmt.Errorf("error resolving %s: %w", key, err)
+			}
+			values[key] = value
+			secrets = append(secrets, value)
+		}
+
+		session, err := writeSecretFiles(cfg, values)
+		if err != nil {
+			return fmt.Errorf("error writing secret files: %w", err)
+		}
+		for _, key := range keys {
+			env = setEnv(env, key, values[key])
+		}
+
+		c := exec.Command(args[0], args[1:]...)
+		c.Env = env
+		c.Stdin = cmd.InOrStdin()
+		c.Stdout = cmd.OutOrStdout()
+		c.Stderr = cmd.ErrOrStderr()
+
+		// Redacting requires pipes, so the child loses its TTY. Without
+		// --redact it writes to our stdout and stderr directly.
+		var redactors []*redact.Writer
+		if execRedact {
+			patterns := redact.Patterns(secrets)
+			stdout := redact.NewWriter(c.Stdout, patterns)
+			stderr := redact.NewWriter(c.Stderr, patterns)
+			c.Stdout, c.Stderr = stdout, stderr
+			redactors = append(redactors, stdout, stderr)
+		}
+
+		code, err := runCommand(c)
+		if err != nil {
+			log.Err("Failed to run %s: %v", args[0], err)
+		}
+		for _, r := range redactors {
+			r.Flush()
+		}
+		// runCommand only returns once the child has exited, including when
+		// we were interrupted, so this also runs on SIGINT and SIGTERM.
+		if session != nil {
+			if err := session.Remove(); err != nil {
+				log.Err("Failed to remove secret files: %v", err)
+			}
+		}
+		if code != 0 {
+			return exitCode(code)
+		}
+		return nil
+	},
+}
+
+// setEnv sets key to value in env, replacing an existing entry.
+func setEnv(env []string, key, value string) []string {
+	prefix := key + "="
+	for i, kv := range env {
+		if strings.HasPrefix(kv, prefix) {
+			env[i] = prefix + value
+			return env
+		}
+	}
+	return append(env, prefix+value)
+}
+
+// runCommand starts c, forwards termination signals to it while it runs and
+// returns its exit code. An error is only returned if c could not be run.
+func runCommand(c *exec.Cmd) (int, error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+
+	if err := c.Start(); err != nil {
+		return 127, err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				c.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := c.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code >= 0 {
+			return code, nil
+		}
+		// Terminated by a signal, reported like shells do.
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal()), nil
+		}
+		return 1, nil
+	}
+	if err != nil {
+		return 1, fmt.Errorf("wait: %w", err)
+	}
+	return 0, nil
+}
+
+func init() {
+	execCmd.Flags().StringSliceVar(&execKeys, "keys", nil, "Comma-separated keys to export (default all keys in config)")
+	execCmd.Flags().BoolVar(&execRedact, "redact", false, "Replace secret values in the command's output with ***")
+	execCmd.Flags().SetInterspersed(false)
+	rootCmd.AddCommand(execCmd)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dvcrn/chainenv/chainenv"
+	"github.com/dvcrn/chainenv/export"
+	"github.com/dvcrn/chainenv/fsutil"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat    string
+	exportOutput    string
+	exportKeys      []string
+	exportName      string
+	exportNamespace string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export secrets to a file",
+	Long: `Resolve the keys declared in config and write them to a file or stdout.
+Supported formats: ` + strings.Join(export.Formats, ", ") + `.
+
+Files are written with 0600 permissions, e.g.:
+  chainenv export --format dotenv -o .env
+  chainenv export --format k8s-secret --name app --keys DB_URL,API_KEY`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+
+		keys := exportKeys
+		if len(keys) == 0 {
+			if cfg == nil {
+				return errors.New("no config found")
+			}
+			keys = chainenv.KeyNames(cfg)
+		}
+		if len(keys) == 0 {
+			return errors.New("no keys found")
+		}
+
+		log.Debug("Exporting keys: %s, format=%s", strings.Join(keys, ", "), exportFormat)
+
+		values, err := newResolver(cfg).ResolveAll(keys)
+		if err != nil {
+			return fmt.Errorf("error resolving secrets: %w", err)
+		}
+
+		data, err := export.Render(exportFormat, values, export.Options{
+			Name:      exportName,
+			Namespace: exportNamespace,
+		})
+		if err != nil {
+			return fmt.Errorf("error rendering output: %w", err)
+		}
+
+		if exportOutput == "" || exportOutput == "-" {
+			_, err := cmd.OutOrStdout().Write(data)
+			return err
+		}
+
+		if unignoredInGitWorkTree(exportOutput) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s is inside a git working tree and not ignored by git\n", exportOutput)
+		}
+
+		if err := fsutil.WriteFileAtomic(exportOutput, data, 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", exportOutput, err)
+		}
+
+		fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d keys to %s\n", len(values), exportOutput)
+		return nil
+	},
+}
+
+// unignoredInGitWorkTree reports whether path lies inside a git working tree
+// that does not ignore it. Any failure to run git is treated as "no".
+func unignoredInGitWorkTree(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	dir := filepath.Dir(abs)
+	if _, err := os.Stat(dir); err != nil {
+		return false
+	}
+
+	if err := exec.Command("git", "-C", dir, "rev-parse", "--is-inside-work-tree").Run(); err != nil {
+		return false
+	}
+
+	// check-ignore exits 0 when ignored, 1 when not ignored and 128 on errors.
+	err = exec.Command("git", "-C", dir, "check-ignore", "-q", abs).Run()
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == 1
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", export.FormatDotenv, "Output format ("+strings.Join(export.Formats, ", ")+")")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "File to write to (default stdout)")
+	exportCmd.Flags().StringSliceVar(&exportKeys, "keys", nil, "Comma-separated keys to export (default all keys in config)")
+	exportCmd.Flags().StringVar(&exportName, "name", "", "metadata.name for Kubernetes manifests")
+	exportCmd.Flags().StringVar(&exportNamespace, "namespace", "", "metadata.namespace for Kubernetes manifests")
+	rootCmd.AddCommand(exportCmd)
+}
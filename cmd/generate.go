@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/config"
+	"github.com/dvcrn/chainenv/secretgen"
+	"github.com/spf13/cobra"
+)
+
+var (
+	genPolicy secretgen.Policy
+	genUpdate bool
+	genPrint  bool
+)
+
+var generateCmd = &cobra.Command{
+	Use:     "generate [account]",
+	Aliases: []string{"gen"},
+	Short:   "Generate and store a random password",
+	Long: `Generate a cryptographically random value, store it in the backend and register the key in config.
+Generation flags are saved as the key's policy in config, so later runs without flags follow the same rules, e.g.:
+  chainenv generate DB_PASSWORD --length 48 --charset symbols
+  chainenv generate WIFI_PASSPHRASE --mode passphrase --words 6
+  chainenv generate DB_PASSWORD --update`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		account := args[0]
+		log.Debug("Generating password for account: %s", account)
+
+		configPath, cfg, err := loadConfigForWrite()
+		if err != nil {
+			return err
+		}
+
+		entry := config.KeyEntry{Name: account, Provider: backendType}
+		if existing, ok := cfg.FindKey(account); ok {
+			entry = *existing
+			if entry.Provider == "" || cmd.Flags().Changed("backend") {
+				entry.Provider = backendType
+			}
+		}
+
+		policy, changed := generatePolicyFromFlags(cmd, entry.Generate)
+		if changed {
+			entry.Generate = &policy
+		}
+
+		password, err := secretgen.Generate(policy)
+		if err != nil {
+			return fmt.Errorf("failed to generate password: %w", err)
+		}
+
+		b, err := getBackendWithType(entry.Provider)
+		if err != nil {
+			return fmt.Errorf("error initializing backend: %w", err)
+		}
+
+		if err := storePassword(b, account, password, genUpdate); err != nil {
+			return fmt.Errorf("failed to store password: %w", err)
+		}
+
+		cfg.UpsertKey(entry)
+		if err := config.Save(configPath, cfg); err != nil {
+			return fmt.Errorf("failed to write config: %w", err)
+		}
+
+		if genPrint {
+			fmt.Fprintln(cmd.OutOrStdout(), password)
+			return nil
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Generated password for %s\n", account)
+		return nil
+	},
+}
+
+// storePassword creates the account in b, or overwrites it if update is set
+// and the account already exists.
+func storePassword(b backend.Backend, account, password string, update bool) error {
+	if !update {
+		return b.SetPassword(account, password, false)
+	}
+
+	if _, err := b.GetPassword(account); err != nil {
+		if errors.Is(err, backend.ErrNotFound) {
+			return b.SetPassword(account, password, false)
+		}
+		return err
+	}
+	return b.SetPassword(account, password, true)
+}
+
+// addGeneratePolicyFlags registers the flags describing a secretgen.Policy.
+func addGeneratePolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&genPolicy.Mode, "mode", "", "Generation mode (chars, passphrase, hex, base64) (default chars)")
+	cmd.Flags().IntVar(&genPolicy.Length, "length", 0, fmt.Sprintf("Number of characters, or random bytes for hex/base64 (default %d)", secretgen.DefaultLength))
+	cmd.Flags().StringVar(&genPolicy.Charset, "charset", "", "Character set (alphanumeric, alpha, lower, lower-alnum, numeric, symbols) (default alphanumeric)")
+	cmd.Flags().StringVar(&genPolicy.Chars, "chars", "", "Custom characters to pick from, overrides --charset")
+	cmd.Flags().IntVar(&genPolicy.Words, "words", 0, fmt.Sprintf("Number of words in passphrase mode (default %d)", secretgen.DefaultWords))
+	cmd.Flags().StringVar(&genPolicy.Separator, "separator", "", fmt.Sprintf("Word separator in passphrase mode (default %q)", secretgen.DefaultSeparator))
+}
+
+// generatePolicyFromFlags overlays the generation flags that were set on the
+// command line onto base. It reports whether any flag was set.
+func generatePolicyFromFlags(cmd *cobra.Command, base *secretgen.Policy) (secretgen.Policy, bool) {
+	var policy secretgen.Policy
+	if base != nil {
+		policy = *base
+	}
+
+	flags := cmd.Flags()
+	changed := false
+	if flags.Changed("mode") {
+		// Switching modes starts from a clean policy so options of the old
+		// mode don't leak into the new one.
+		if genPolicy.Mode != policy.Mode {
+			policy = secretgen.Policy{}
+		}
+		policy.Mode = genPolicy.Mode
+		changed = true
+	}
+	if flags.Changed("length") {
+		policy.Length = genPolicy.Length
+		changed = true
+	}
+	if flags.Changed("charset") {
+		policy.Charset = genPolicy.Charset
+		policy.Chars = ""
+		changed = true
+	}
+	if flags.Changed("chars") {
+		policy.Chars = genPolicy.Chars
+		changed = true
+	}
+	if flags.Changed("words") {
+		policy.Words = genPolicy.Words
+		changed = true
+	}
+	if flags.Changed("separator") {
+		policy.Separator = genPolicy.Separator
+		changed = true
+	}
+
+	return policy, changed
+}
+
+func init() {
+	addGeneratePolicyFlags(generateCmd)
+	generateCmd.Flags().BoolVar(&genUpdate, "update", false, "Overwrite the password if the account already exists")
+	generateCmd.Flags().BoolVar(&genPrint, "print", false, "Print the generated password to stdout")
+	rootCmd.AddCommand(generateCmd)
+}
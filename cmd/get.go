@@ -3,9 +3,9 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"os"
 
 	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/chainenv"
 	"github.com/spf13/cobra"
 )
 
@@ -14,34 +14,32 @@ var getCmd = &cobra.Command{
 	Short: "Get a password for an account",
 	Long:  `Retrieve a password stored for the specified account.`,
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		account := args[0]
 		log.Debug("Getting password for account: %s", account)
 
 		cfg, err := loadConfig()
 		if err != nil {
-			log.Err("Error loading config: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("error loading config: %w", err)
 		}
 
-		provider, defaultValue := resolveKeyConfig(cfg, account, backendType)
+		provider, defaultValue := chainenv.KeyConfig(cfg, account, backendType)
 		b, err := getBackendWithType(provider)
 		if err != nil {
-			log.Err("Error initializing backend: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("error initializing backend: %w", err)
 		}
 
 		password, err := b.GetPassword(account)
 		if err != nil {
 			if errors.Is(err, backend.ErrNotFound) && defaultValue != nil {
-				fmt.Println(*defaultValue)
-				return
+				fmt.Fprintln(cmd.OutOrStdout(), *defaultValue)
+				return nil
 			}
-			log.Err("Error retrieving password: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("error retrieving password: %w", err)
 		}
 
-		fmt.Println(password)
+		fmt.Fprintln(cmd.OutOrStdout(), password)
+		return nil
 	},
 }
 
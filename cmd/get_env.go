@@ -1,51 +1,209 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"sort"
 	"strings"
 
-	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/chainenv"
+	"github.com/dvcrn/chainenv/ci"
+	"github.com/dvcrn/chainenv/secretfile"
 	"github.com/spf13/cobra"
 )
 
 var (
-	shellType string
-	fishFlag  bool
-	bashFlag  bool
-	zshFlag   bool
+	shellType  string
+	ciProvider string
+	fishFlag   bool
+	bashFlag   bool
+	zshFlag    bool
+	tfVars     bool
 )
 
+// tfVarPrefix is the prefix of environment variables Terraform reads input
+// variables from.
+const tfVarPrefix = "TF_VAR_"
+
+// tfVarName returns the environment variable Terraform reads the variable
+// named like key in lower case from.
+func tfVarName(key string) string {
+	return tfVarPrefix + strings.ToLower(key)
+}
+
+// checkTFVarNames returns an error if two different keys map to the same
+// Terraform variable, e.g. FOO and foo.
+func checkTFVarNames(keys []string) error {
+	seen := make(map[string]string)
+	for _, key := range keys {
+		name := tfVarName(key)
+		if other, ok := seen[name]; ok && other != key {
+			return fmt.Errorf("%s and %s both map to %s", other, key, name)
+		}
+		seen[name] = key
+	}
+	return nil
+}
+
+// tfVarNames renames every key to its Terraform variable. Keys must have been
+// checked with checkTFVarNames.
+func tfVarNames(passwords map[string]string) map[string]string {
+	renamed := make(map[string]string, len(passwords))
+	for key, value := range passwords {
+		renamed[tfVarName(key)] = value
+	}
+	return renamed
+}
+
 func formatShellExports(accountsPasswords map[string]string, shell string) string {
 	if len(accountsPasswords) == 0 {
 		return ""
 	}
 
+	accounts := make([]string, 0, len(accountsPasswords))
+	for account := range accountsPasswords {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
 	var exports []string
-	for account, password := range accountsPasswords {
+	for _, account := range accounts {
+		password := accountsPasswords[account]
 		var format string
 		switch shell {
 		case "fish":
-			format = "set -x %s '%s'"
+			format = "set -x %s %s"
 		case "bash", "zsh", "sh":
-			format = "export %s='%s'"
+			format = "export %s=%s"
 		default:
-			format = "%s='%s'"
+			format = "%s=%s"
 		}
-		exports = append(exports, fmt.Sprintf(format, account, password))
+		exports = append(exports, fmt.Sprintf(format, account, shellQuote(password, shell)))
 	}
 	return strings.Join(exports, "\n")
 }
 
+// shellQuote single-quotes value for shell. Single quotes keep multiline
+// values intact; embedded single quotes are escaped.
+func shellQuote(value, shell string) string {
+	if shell == "fish" {
+		value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+		return "'" + value + "'"
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// writeCIOutput makes passwords available to later steps of a CI job in the
+// way the provider expects, masking them in the job log where supported.
+func writeCIOutput(stdout, stderr io.Writer, provider string, passwords map[string]string) error {
+	keys := make([]string, 0, len(passwords))
+	for k := range passwords {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	switch provider {
+	case ci.GitHub:
+		envFile := os.Getenv("GITHUB_ENV")
+		if envFile == "" {
+			return fmt.Errorf("GITHUB_ENV is not set")
+		}
+		f, err := os.OpenFile(envFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o600)
+		if err != nil {
+			return fmt.Errorf("open GITHUB_ENV: %w", err)
+		}
+		defer f.Close()
+
+		// Masks have to be registered before the values can show up in
+		// the log, so write all of them first.
+		for _, k := range keys {
+			if err := ci.WriteGitHubMasks(stdout, passwords[k]); err != nil {
+				return err
+			}
+		}
+		for _, k := range keys {
+			if err := ci.WriteGitHubEnv(f, k, passwords[k]); err != nil {
+				return fmt.Errorf("write GITHUB_ENV: %w", err)
+			}
+		}
+		fmt.Fprintf(stderr, "Exported %d keys to GITHUB_ENV\n", len(keys))
+		return f.Close()
+	case ci.Buildkite:
+		for _, k := range keys {
+			if err := ci.AddBuildkiteRedaction(passwords[k]); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(stdout, formatShellExports(passwords, "bash"))
+		return nil
+	case ci.GitLab:
+		// GitLab can only mask variables defined in the project settings,
+		// so there is nothing to register at runtime.
+		fmt.Fprintln(stderr, "Warning: GitLab cannot mask values at runtime; avoid printing them in the job log")
+		fmt.Fprintln(stdout, formatShellExports(passwords, "bash"))
+		return nil
+	default:
+		return fmt.Errorf("unknown CI provider: %s (supported: %s)", provider, strings.Join(ci.Providers, ", "))
+	}
+}
+
+// Special values of get-env --ci.
+const (
+	ciAuto = "auto"
+	ciNone = "none"
+)
+
+// getEnvCIProvider returns the CI provider get-env writes its output for, or
+// "" to print shell exports. Without --ci, the provider is detected from the
+// environment unless a shell format was asked for explicitly.
+func getEnvCIProvider(cmd *cobra.Command) (string, error) {
+	switch ciProvider {
+	case ciNone:
+		return "", nil
+	case "":
+		for _, name := range []string{"shell", "fish", "bash", "zsh"} {
+			if cmd.Flags().Changed(name) {
+				return "", nil
+			}
+		}
+		provider, ok := ci.Detect()
+		if ok {
+			log.Debug("Detected CI provider %s, use --ci none for shell exports", provider)
+		}
+		return provider, nil
+	case ciAuto:
+		provider, ok := ci.Detect()
+		if !ok {
+			return "", fmt.Errorf("no supported CI environment detected, use --ci %s", strings.Join(ci.Providers, "|"))
+		}
+		return provider, nil
+	}
+	if !slices.Contains(ci.Providers, ciProvider) {
+		return "", fmt.Errorf("unknown CI provider: %s (supported: %s, %s, %s)", ciProvider, strings.Join(ci.Providers, ", "), ciAuto, ciNone)
+	}
+	return ciProvider, nil
+}
+
 var getEnvCmd = &cobra.Command{
 	Use:   "get-env [account1,account2,...]",
 	Short: "Get passwords as environment variables",
 	Long: `Retrieve passwords for multiple accounts and format them as environment variables.
 Multiple accounts should be provided as a comma-separated list, e.g.:
-  chainenv get-env AWS_KEY,AWS_SECRET --shell fish`,
+  chainenv get-env AWS_KEY,AWS_SECRET --shell fish
+
+Keys configured with file = true are written to private 0600 files and exported as paths.
+The files stay around until removed with 'chainenv cleanup'.
+
+With --tf-vars, keys are exported as TF_VAR_<name in lower case> for use as Terraform variables,
+and nothing is exported if any key is missing.
+
+In GitHub Actions, GitLab CI and Buildkite, values are made available to later steps of the job and
+masked in its log (see --ci), unless a shell format is given or --ci none is used.`,
 	Args: cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
 		var accounts []string
 		if len(args) > 0 {
 			accounts = strings.Split(args[0], ",")
@@ -60,79 +218,79 @@ Multiple accounts should be provided as a comma-separated list, e.g.:
 			shellType = "zsh"
 		}
 
+		provider, err := getEnvCIProvider(cmd)
+		if err != nil {
+			return err
+		}
+
 		cfg, err := loadConfig()
 		if err != nil {
-			log.Err("Error loading config: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("error loading config: %w", err)
 		}
 
 		if len(accounts) == 0 {
 			if cfg == nil {
-				fmt.Fprintln(os.Stderr, "No config found")
-				os.Exit(1)
-			}
-			for _, entry := range cfg.Keys {
-				if entry.Name == "" {
-					continue
-				}
-				accounts = append(accounts, entry.Name)
+				fmt.Fprintln(stderr, "No config found")
+				return exitCode(1)
 			}
+			accounts = chainenv.KeyNames(cfg)
 			if len(accounts) == 0 {
-				fmt.Fprintln(os.Stderr, "No keys found")
-				return
+				fmt.Fprintln(stderr, "No keys found")
+				return nil
+			}
+		}
+
+		if tfVars {
+			if err := checkTFVarNames(accounts); err != nil {
+				return fmt.Errorf("cannot use --tf-vars: %w", err)
 			}
 		}
 
 		log.Debug("Getting passwords for accounts: %s, shell=%s", strings.Join(accounts, ", "), shellType)
 
-		backends := make(map[string]backend.Backend)
-		getBackend := func(provider string) (backend.Backend, error) {
-			if cached, ok := backends[provider]; ok {
-				return cached, nil
-			}
-			b, err := getBackendWithType(provider)
-			if err != nil {
-				return nil, err
-			}
-			backends[provider] = b
-			return b, nil
-		}
-
-		passwords := make(map[string]string)
-		var firstErr error
-		for _, account := range accounts {
-			provider, defaultValue := resolveKeyConfig(cfg, account, backendType)
-			b, err := getBackend(provider)
-			if err != nil {
-				if firstErr == nil {
-					firstErr = err
-				}
-				continue
-			}
+		passwords, firstErr := newResolver(cfg).ResolveAll(accounts)
+		// Terraform prompts for or defaults variables that aren't set, so
+		// don't hand it a partial set.
+		if tfVars && firstErr != nil {
+			fmt.Fprintln(stderr, firstErr.Error())
+			return exitCode(1)
+		}
 
-			password, err := b.GetPassword(account)
-			if err != nil {
-				if errors.Is(err, backend.ErrNotFound) && defaultValue != nil {
-					passwords[account] = *defaultValue
-					continue
-				}
-				if firstErr == nil {
-					firstErr = err
+		session, err := writeSecretFiles(cfg, passwords)
+		if err != nil {
+			return fmt.Errorf("error writing secret files: %w", err)
+		}
+		if tfVars {
+			passwords = tfVarNames(passwords)
+		}
+		if session != nil {
+			passwords[secretfile.DirEnv] = session.Dir
+		}
+
+		if provider != "" {
+			if len(passwords) == 0 {
+				fmt.Fprintln(stderr, "No passwords found")
+				if firstErr != nil {
+					fmt.Fprintln(stderr, firstErr.Error())
 				}
-				continue
+				return exitCode(1)
+			}
+			if err := writeCIOutput(stdout, stderr, provider, passwords); err != nil {
+				return fmt.Errorf("error writing CI output: %w", err)
 			}
-			passwords[account] = password
+			return nil
 		}
 
 		output := formatShellExports(passwords, shellType)
 		if output == "" {
-			fmt.Fprintln(os.Stderr, "No passwords found")
+			fmt.Fprintln(stderr, "No passwords found")
 			if firstErr != nil {
-				fmt.Fprintln(os.Stderr, firstErr.Error())
+				fmt.Fprintln(stderr, firstErr.Error())
 			}
-			os.Exit(1)
+			return exitCode(1)
 		}
-		fmt.Println(output)
+		fmt.Fprintln(stdout, output)
+		return nil
 	},
 }
 
@@ -140,6 +298,10 @@ func init() {
 	// New style
 	getEnvCmd.Flags().StringVar(&shellType, "shell", "plain", "Shell format (fish, bash, zsh)")
 
+	getEnvCmd.Flags().StringVar(&ciProvider, "ci", "", "CI output target ("+strings.Join(ci.Providers, ", ")+", auto or none); detected from the environment unless a shell format is given")
+
+	getEnvCmd.Flags().BoolVar(&tfVars, "tf-vars", false, "Export keys as TF_VAR_<lowercase name> for Terraform")
+
 	// Legacy style
 	getEnvCmd.Flags().BoolVar(&fishFlag, "fish", false, "Use fish shell format (legacy)")
 	getEnvCmd.Flags().BoolVar(&bashFlag, "bash", false, "Use bash shell format (legacy)")
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/config"
+	"github.com/dvcrn/chainenv/gitcredential"
+	"github.com/spf13/cobra"
+)
+
+var gitCredentialConfig string
+
+var gitCredentialCmd = &cobra.Command{
+	Use:   "git-credential <get|store|erase>",
+	Short: "Serve git credentials from the backend",
+	Long: `Act as a git credential helper, serving passwords and tokens for the hosts listed under
+[[git_credentials]] in config from the configured backend. store saves the password git used and erase
+deletes it, unless the stored password differs from the rejected one. Requests for hosts without an entry are ignored, so git falls back to other helpers.
+
+Register it with a leading "!" so git runs it as a shell command, e.g.:
+  git config --global credential.https://git.example.com.helper '!chainenv git-credential --config ~/.chainenv.toml'`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"get", "store", "erase"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req, err := gitcredential.Read(cmd.InOrStdin())
+		if err != nil {
+			return fmt.Errorf("invalid credential request: %w", err)
+		}
+
+		var cfg *config.Config
+		if gitCredentialConfig != "" {
+			cfg, err = config.Load(gitCredentialConfig)
+		} else {
+			cfg, err = loadConfig()
+		}
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+		if cfg == nil {
+			log.Debug("No config found, ignoring credential request")
+			return nil
+		}
+
+		entry, ok := cfg.FindGitCredential(req.Protocol, req.Host, req.Username)
+		if !ok {
+			log.Debug("No git credential entry for %s://%s", req.Protocol, req.Host)
+			return nil
+		}
+
+		resolver := newResolver(cfg)
+		provider, _ := resolver.KeyConfig(entry.Key)
+		if entry.Provider != "" {
+			provider = entry.Provider
+		}
+
+		// Git ignores unknown operations so that helpers keep working with
+		// newer versions, and so do we.
+		switch args[0] {
+		case "get":
+			password, _, err := resolver.ResolveWithProvider(entry.Key, provider)
+			if errors.Is(err, backend.ErrNotFound) {
+				log.Debug("%s not found", entry.Key)
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("error getting %s: %w", entry.Key, err)
+			}
+			username := req.Username
+			if username == "" {
+				username = entry.Username
+			}
+			if err := gitcredential.Write(cmd.OutOrStdout(), gitcredential.Credential{Username: username, Password: password}); err != nil {
+				return fmt.Errorf("error writing credential: %w", err)
+			}
+
+		case "store":
+			if req.Password == "" {
+				return nil
+			}
+			b, err := resolver.Backend(provider)
+			if err != nil {
+				return fmt.Errorf("error initializing backend: %w", err)
+			}
+			// Git stores credentials after every successful use, so avoid
+			// rewriting an unchanged value.
+			if current, err := b.GetPassword(entry.Key); err == nil && current == req.Password {
+				return nil
+			}
+			if err := storePassword(b, entry.Key, req.Password, true); err != nil {
+				return fmt.Errorf("error storing %s: %w", entry.Key, err)
+			}
+
+		case "erase":
+			b, err := resolver.Backend(provider)
+			if err != nil {
+				return fmt.Errorf("error initializing backend: %w", err)
+			}
+			// Git erases the credential it just failed with. If it isn't the
+			// stored one anymore, e.g. because another process already
+			// stored a new one, keep it. Like git, treat a missing
+			// password as matching any.
+			if req.Password != "" {
+				current, err := b.GetPassword(entry.Key)
+				if errors.Is(err, backend.ErrNotFound) {
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("error getting %s: %w", entry.Key, err)
+				}
+				if current != req.Password {
+					log.Debug("%s does not match the rejected password, keeping it", entry.Key)
+					return nil
+				}
+			}
+			if err := b.DeletePassword(entry.Key); err != nil && !errors.Is(err, backend.ErrNotFound) {
+				return fmt.Errorf("error erasing %s: %w", entry.Key, err)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	gitCredentialCmd.Flags().StringVar(&gitCredentialConfig, "config", "", "Config to read [[git_credentials]] from (default the discovered config)")
+	rootCmd.AddCommand(gitCredentialCmd)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dvcrn/chainenv/fsutil"
+	"github.com/dvcrn/chainenv/ref"
+	"github.com/spf13/cobra"
+)
+
+var (
+	injectInput  string
+	injectOutput string
+)
+
+var injectCmd = &cobra.Command{
+	Use:   "inject",
+	Short: "Replace secret references in a file",
+	Long: `Replace chainenv://[provider/]KEY references in a file or stdin with their values.
+Without a provider, the key's configured provider is used. Output files are written atomically with 0600 permissions, e.g.:
+  chainenv inject -i config.yml.tpl -o config.yml
+  cat .env.tpl | chainenv inject`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var data []byte
+		var err error
+		if injectInput == "" || injectInput == "-" {
+			data, err = io.ReadAll(cmd.InOrStdin())
+		} else {
+			data, err = os.ReadFile(injectInput)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+
+		out, err := ref.Replace(string(data), newResolver(cfg).ResolveReference)
+		if err != nil {
+			return fmt.Errorf("error resolving reference: %w", err)
+		}
+
+		if injectOutput == "" || injectOutput == "-" {
+			_, err := io.WriteString(cmd.OutOrStdout(), out)
+			return err
+		}
+
+		if err := fsutil.WriteFileAtomic(injectOutput, []byte(out), 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", injectOutput, err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	injectCmd.Flags().StringVarP(&injectInput, "in-file", "i", "", "File to read (default stdin)")
+	injectCmd.Flags().StringVarP(&injectOutput, "out-file", "o", "", "File to write to (default stdout)")
+	rootCmd.AddCommand(injectCmd)
+}
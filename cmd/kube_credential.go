@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	kubeExecInfoEnv    = "KUBERNETES_EXEC_INFO"
+	kubeAuthAPIVersion = "client.authentication.k8s.io/v1"
+)
+
+var (
+	kubeClientCertKey string
+	kubeClientKeyKey  string
+	kubeExpirationKey string
+)
+
+// execCredential is the ExecCredential object read by client-go from exec
+// credential plugins.
+type execCredential struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Status     execCredentialStatus `json:"status"`
+}
+
+type execCredentialStatus struct {
+	ExpirationTimestamp   string `json:"expirationTimestamp,omitempty"`
+	Token                 string `json:"token,omitempty"`
+	ClientCertificateData string `json:"clientCertificateData,omitempty"`
+	ClientKeyData         string `json:"clientKeyData,omitempty"`
+}
+
+var kubeCredentialCmd = &cobra.Command{
+	Use:   "kube-credential [token-key]",
+	Short: "Print Kubernetes credentials for a kubeconfig exec plugin",
+	Long: `Print an ExecCredential holding the bearer token stored in token-key, or the PEM client certificate
+and key stored in --client-certificate and --client-key, for use as a kubeconfig exec credential plugin:
+  users:
+  - name: prod
+    user:
+      exec:
+        apiVersion: client.authentication.k8s.io/v1
+        command: chainenv
+        args: ["kube-credential", "KUBE_PROD_TOKEN"]
+        interactiveMode: Never
+
+With --expiration, the key must hold an RFC 3339 timestamp after which kubectl asks for new credentials.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useToken := len(args) == 1
+		useCert := kubeClientCertKey != "" || kubeClientKeyKey != ""
+		switch {
+		case useToken && useCert:
+			return errors.New("use either a token key or --client-certificate and --client-key, not both")
+		case !useToken && !useCert:
+			return errors.New("specify a token key or --client-certificate and --client-key")
+		case useCert && (kubeClientCertKey == "" || kubeClientKeyKey == ""):
+			return errors.New("--client-certificate and --client-key must be used together")
+		}
+
+		apiVersion, err := kubeExecAPIVersion()
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", kubeExecInfoEnv, err)
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+
+		keys := []string{kubeClientCertKey, kubeClientKeyKey}
+		if useToken {
+			keys = []string{args[0]}
+		}
+		resolver := newResolver(cfg)
+		values, err := resolver.ResolveAll(keys)
+		if err != nil {
+			return fmt.Errorf("error getting credentials: %w", err)
+		}
+
+		cred := execCredential{APIVersion: apiVersion, Kind: "ExecCredential"}
+		if useToken {
+			cred.Status.Token = values[args[0]]
+		} else {
+			cred.Status.ClientCertificateData = values[kubeClientCertKey]
+			cred.Status.ClientKeyData = values[kubeClientKeyKey]
+		}
+		if kubeExpirationKey != "" {
+			expiration, err := resolveExpiration(resolver, kubeExpirationKey)
+			if err != nil {
+				return fmt.Errorf("error getting expiration: %w", err)
+			}
+			cred.Status.ExpirationTimestamp = expiration.UTC().Format(time.RFC3339)
+		}
+
+		out, err := json.Marshal(cred)
+		if err != nil {
+			return fmt.Errorf("error encoding credential: %w", err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(out))
+		return nil
+	},
+}
+
+// kubeExecAPIVersion returns the ExecCredential version client-go asked for
+// through KUBERNETES_EXEC_INFO, defaulting to v1.
+func kubeExecAPIVersion() (string, error) {
+	info := os.Getenv(kubeExecInfoEnv)
+	if info == "" {
+		return kubeAuthAPIVersion, nil
+	}
+	var req struct {
+		APIVersion string `json:"apiVersion"`
+	}
+	if err := json.Unmarshal([]byte(info), &req); err != nil {
+		return "", err
+	}
+	switch req.APIVersion {
+	case "":
+		return kubeAuthAPIVersion, nil
+	case kubeAuthAPIVersion, "client.authentication.k8s.io/v1beta1":
+		return req.APIVersion, nil
+	default:
+		return "", fmt.Errorf("unsupported apiVersion %q", req.APIVersion)
+	}
+}
+
+func init() {
+	kubeCredentialCmd.Flags().StringVar(&kubeClientCertKey, "client-certificate", "", "Key holding the PEM client certificate")
+	kubeCredentialCmd.Flags().StringVar(&kubeClientKeyKey, "client-key", "", "Key holding the PEM client key")
+	kubeCredentialCmd.Flags().StringVar(&kubeExpirationKey, "expiration", "", "Key holding the RFC 3339 expiration time of the credentials")
+	rootCmd.AddCommand(kubeCredentialCmd)
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 )
@@ -11,25 +10,26 @@ var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List keys declared in config",
 	Long:  "List keys declared in .chainenv.toml or chainenv.toml.",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := loadConfig()
 		if err != nil {
-			log.Err("Error loading config: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("error loading config: %w", err)
 		}
 		if cfg == nil {
-			fmt.Fprintln(os.Stderr, "No config found")
-			os.Exit(1)
+			fmt.Fprintln(cmd.ErrOrStderr(), "No config found")
+			return exitCode(1)
 		}
 
+		out := cmd.OutOrStdout()
 		if len(cfg.Keys) == 0 {
-			fmt.Println("No keys found")
-			return
+			fmt.Fprintln(out, "No keys found")
+			return nil
 		}
 
 		for _, entry := range cfg.Keys {
-			fmt.Println(entry.Name)
+			fmt.Fprintln(out, entry.Name)
 		}
+		return nil
 	},
 }
 
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"sort"
 
 	"github.com/spf13/cobra"
@@ -12,32 +11,32 @@ var lsCmd = &cobra.Command{
 	Use:   "ls",
 	Short: "List all stored accounts",
 	Long:  `List all accounts that have passwords stored in the configured backend.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		log.Debug("Listing all accounts")
 
 		b, err := getBackendWithType(backendType)
 		if err != nil {
-			log.Err("Error initializing backend: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("error initializing backend: %w", err)
 		}
 
 		accounts, err := b.List()
 		if err != nil {
-			log.Err("Error listing accounts: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("error listing accounts: %w", err)
 		}
 
+		out := cmd.OutOrStdout()
 		if len(accounts) == 0 {
-			fmt.Println("No accounts found")
-			return
+			fmt.Fprintln(out, "No accounts found")
+			return nil
 		}
 
 		// Sort accounts alphabetically
 		sort.Strings(accounts)
 
 		for _, account := range accounts {
-			fmt.Println(account)
+			fmt.Fprintln(out, account)
 		}
+		return nil
 	},
 }
 
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/fsutil"
+	"github.com/dvcrn/chainenv/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	renderOutput string
+	renderCheck  bool
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render [template]",
+	Short: "Render a template containing secrets",
+	Long: `Render a Go text/template, resolving {{ secret "NAME" }} through the configured providers.
+Output files are written atomically with 0600 permissions. Use "-" to read the template from stdin, e.g.:
+  chainenv render npmrc.tmpl -o ~/.npmrc
+  chainenv render settings.xml.tmpl --check`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var text []byte
+		var err error
+		name := args[0]
+		if name == "-" {
+			text, err = io.ReadAll(cmd.InOrStdin())
+		} else {
+			text, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read template: %w", err)
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+
+		resolver := newResolver(cfg)
+		cache := make(map[string]string)
+		lookup := func(key string) (string, error) {
+			if value, ok := cache[key]; ok {
+				return value, nil
+			}
+			value, _, err := resolver.Resolve(key)
+			if err != nil {
+				return "", err
+			}
+			cache[key] = value
+			return value, nil
+		}
+
+		tmpl, err := render.Parse(filepath.Base(name), string(text), lookup)
+		if err != nil {
+			return fmt.Errorf("failed to parse template: %w", err)
+		}
+
+		stdout := cmd.OutOrStdout()
+		if renderCheck {
+			keys, dynamic := tmpl.References()
+			failed := false
+			for _, key := range keys {
+				if _, err := lookup(key); err != nil {
+					if errors.Is(err, backend.ErrNotFound) {
+						fmt.Fprintf(stdout, "%s: missing\n", key)
+					} else {
+						fmt.Fprintf(stdout, "%s: error: %v\n", key, err)
+					}
+					failed = true
+					continue
+				}
+				fmt.Fprintf(stdout, "%s: ok\n", key)
+			}
+			if dynamic > 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %d secret calls with non-literal arguments were not checked\n", dynamic)
+			}
+			if failed {
+				return exitCode(1)
+			}
+			return nil
+		}
+
+		out, err := tmpl.Execute()
+		if err != nil {
+			return fmt.Errorf("failed to render template: %w", err)
+		}
+
+		if renderOutput == "" || renderOutput == "-" {
+			_, err := stdout.Write(out)
+			return err
+		}
+
+		if err := fsutil.WriteFileAtomic(renderOutput, out, 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", renderOutput, err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	renderCmd.Flags().StringVarP(&renderOutput, "output", "o", "", "File to write to (default stdout)")
+	renderCmd.Flags().BoolVar(&renderCheck, "check", false, "Only verify that all referenced secrets exist")
+	rootCmd.AddCommand(renderCmd)
+}
@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"slices"
 
 	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/chainenv"
+	"github.com/dvcrn/chainenv/config"
 	"github.com/dvcrn/chainenv/logger"
 	"github.com/spf13/cobra"
 )
@@ -13,55 +19,147 @@ var (
 	backendType string
 	opVault     string
 	debug       bool
+	logLevel    string
+	logFormat   string
 	log         *logger.Logger
+	commandPath string
 	version     = "dev"
 )
 
+// Seams replaced by tests.
+var (
+	// backendFactory initializes the backend for a provider in this process,
+	// with the 1Password settings of cfg.
+	backendFactory = newBackend
+	// getwd returns the directory config is discovered from.
+	getwd = os.Getwd
+)
+
 var rootCmd = &cobra.Command{
 	Use:     "chainenv",
 	Short:   "chainenv - A tool for managing environment variables securely",
 	Long:    `chainenv allows you to securely store and retrieve environment variables using different secure backends like macOS Keychain or 1Password.`,
 	Version: version,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		log = logger.NewLogger(debug)
+	// Errors are printed by execute, which also knows about exitError.
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Flags and arguments are valid at this point, so errors returned
+		// from here on aren't usage errors.
+		cmd.SilenceUsage = true
+		l, err := newLogger(cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+		log = l
+		commandPath = cmd.CommandPath()
 		log.Debug("Using backend: %s", backendType)
+		return nil
 	},
 }
 
+// exitError makes the process exit with code. If err is nil, nothing is
+// printed because the command has already reported the problem.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// exitCode returns an error that ends the command with code without printing
+// anything further.
+func exitCode(code int) error {
+	return &exitError{code: code}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	args := os.Args[1:]
+	if invokedAsDockerCredentialHelper() {
+		args = append([]string{dockerCredentialCmd.Name()}, args...)
 	}
+	os.Exit(execute(args, os.Stdin, os.Stdout, os.Stderr))
 }
 
-func getBackendWithType(backendType string) (backend.Backend, error) {
-	opts := backend.WithLogger(log)
+// execute runs the command line args with the given standard streams and
+// returns the exit code.
+func execute(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	rootCmd.SetArgs(args)
+	rootCmd.SetIn(stdin)
+	rootCmd.SetOut(stdout)
+	rootCmd.SetErr(stderr)
+	// Replaced once the logging flags are parsed; used for errors that
+	// happen before that.
+	log = logger.New(stderr, logger.Options{Level: slog.LevelInfo})
 
-	switch backendType {
-	case "keychain":
-		b, err := backend.NewKeychainBackend()
-		if err != nil {
-			return nil, fmt.Errorf("keychain backend unavailable: %w", err)
+	err := rootCmd.Execute()
+	if err == nil {
+		return 0
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		if exitErr.err != nil {
+			log.Err("%v", exitErr.err)
 		}
-		return b, nil
-	case "1password":
-		cfg, err := loadConfig()
-		if err != nil {
+		return exitErr.code
+	}
+	log.Err("%v", err)
+	return 1
+}
+
+// newLogger returns the logger configured by the logging flags. --debug is
+// kept as a shorthand for --log-level debug.
+func newLogger(w io.Writer) (*logger.Logger, error) {
+	level, err := logger.ParseLevel(logLevel)
+	if err != nil {
+		return nil, err
+	}
+	if debug {
+		level = slog.LevelDebug
+	}
+	if !slices.Contains(logger.Formats, logFormat) {
+		return nil, fmt.Errorf("unknown log format %q (expected text or json)", logFormat)
+	}
+	return logger.New(w, logger.Options{Level: level, Format: logFormat}), nil
+}
+
+// getBackendWithType returns the backend for backendType, connected by
+// chainenv.Connect: through the agent configured with CHAINENV_AGENT_SOCK if
+// it can be reached, recording all operations when CHAINENV_AUDIT_LOG is set,
+// and with secret values redacted from the log.
+func getBackendWithType(backendType string) (backend.Backend, error) {
+	var cfg *config.Config
+	if backendType == "1password" {
+		var err error
+		if cfg, err = loadConfig(); err != nil {
 			return nil, fmt.Errorf("error loading config: %w", err)
 		}
-		if err := ensureOpServiceAccountToken(cfg); err != nil {
-			return nil, err
-		}
-		return backend.NewOnePasswordBackend(opVault, opts), nil
-	default:
-		return nil, fmt.Errorf("unknown backend: %s", backendType)
 	}
+	return chainenv.Connect(backendType, opVault, cfg, chainenv.ConnectOptions{
+		Command:    commandPath,
+		Logger:     log,
+		NewBackend: backendFactory,
+	})
+}
+
+// newBackend initializes the backend for backendType in this process.
+func newBackend(backendType, opVault string, cfg *config.Config) (backend.Backend, error) {
+	return chainenv.NewBackend(backendType, opVault, cfg, backend.WithLogger(log))
 }
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&backendType, "backend", "keychain", "Backend to use (keychain or 1password)")
 	rootCmd.PersistentFlags().StringVar(&opVault, "vault", "chainenv", "1Password vault to use")
-	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug logging")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug logging (same as --log-level debug)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Minimum level of messages logged to stderr (debug, info, warn or error)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logger.FormatText, "Format of messages logged to stderr (text or json)")
 }
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/chainenv"
+	"github.com/dvcrn/chainenv/config"
+	"github.com/dvcrn/chainenv/secretgen"
+	"github.com/spf13/cobra"
+)
+
+// previousSuffix is appended to an account name to store the value it had
+// before the last rotation.
+const previousSuffix = ".previous"
+
+var (
+	rotatePrompt   bool
+	rotateHook     string
+	rotateFinalize bool
+)
+
+var rotateCmd = &cobra.Command{
+	Use:   "rotate [account]",
+	Short: "Rotate a password, running a hook before committing it",
+	Long: `Replace the password of an existing account with a new value.
+
+The new value is generated from the key's policy (or the generation flags), or
+read from stdin with --prompt. If the key has a rotation hook configured in
+[keys.rotate] (or --hook is given), it runs first and receives the new value on
+stdin, or as CHAINENV_NEW_VALUE when input = "env". The new value is only
+stored if the hook succeeds.
+
+The previous value is kept as <account>` + previousSuffix + ` until the rotation is
+finalized with --finalize, e.g.:
+  chainenv rotate API_KEY
+  chainenv get API_KEY` + previousSuffix + `
+  chainenv rotate API_KEY --finalize`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stdout := cmd.OutOrStdout()
+		account := args[0]
+		previous := account + previousSuffix
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+
+		provider, _ := chainenv.KeyConfig(cfg, account, backendType)
+		b, err := getBackendWithType(provider)
+		if err != nil {
+			return fmt.Errorf("error initializing backend: %w", err)
+		}
+
+		if rotateFinalize {
+			if err := b.DeletePassword(previous); err != nil {
+				if errors.Is(err, backend.ErrNotFound) {
+					return fmt.Errorf("no rotation in progress for %s", account)
+				}
+				return fmt.Errorf("failed to remove previous password: %w", err)
+			}
+			fmt.Fprintf(stdout, "Rotation finalized for %s\n", account)
+			return nil
+		}
+
+		var entry config.KeyEntry
+		if cfg != nil {
+			if existing, ok := cfg.FindKey(account); ok {
+				entry = *existing
+			}
+		}
+
+		oldValue, err := b.GetPassword(account)
+		if err != nil {
+			return fmt.Errorf("error retrieving current password: %w", err)
+		}
+
+		if _, err := b.GetPassword(previous); err == nil {
+			return fmt.Errorf("a previous rotation of %s has not been finalized. Run `chainenv rotate %s --finalize` first", account, account)
+		} else if !errors.Is(err, backend.ErrNotFound) {
+			return fmt.Errorf("error checking for previous password: %w", err)
+		}
+
+		var newValue string
+		if rotatePrompt {
+			newValue, err = readSecret(cmd, fmt.Sprintf("New value for %s: ", account))
+		} else {
+			policy, _ := generatePolicyFromFlags(cmd, entry.Generate)
+			newValue, err = secretgen.Generate(policy)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to obtain new password: %w", err)
+		}
+		if newValue == "" {
+			return errors.New("new password is empty")
+		}
+
+		hook := entry.Rotate
+		if cmd.Flags().Changed("hook") {
+			hook = &config.RotateConfig{Command: rotateHook}
+			if entry.Rotate != nil {
+				hook.Input = entry.Rotate.Input
+			}
+		}
+
+		// Keep the old value around before touching anything else, so it
+		// stays retrievable no matter how the rest of the rotation goes.
+		if err := b.SetPassword(previous, oldValue, false); err != nil {
+			return fmt.Errorf("failed to store previous password: %w", err)
+		}
+
+		if hook != nil && hook.Command != "" {
+			log.Debug("Running rotation hook for %s: %s", account, hook.Command)
+			if err := runRotateHook(cmd.ErrOrStderr(), hook, account, provider, oldValue, newValue); err != nil {
+				if err := b.DeletePassword(previous); err != nil {
+					log.Err("Failed to remove %s: %v", previous, err)
+				}
+				return fmt.Errorf("rotation hook failed, password not changed: %w", err)
+			}
+		}
+
+		if err := b.SetPassword(account, newValue, true); err != nil {
+			if hook != nil && hook.Command != "" {
+				// The hook has already applied the new value remotely, so
+				// losing it here would lock the user out.
+				log.Err("The rotation hook already succeeded; the new value is printed to stdout so it is not lost.")
+				fmt.Fprintln(stdout, newValue)
+			} else if err := b.DeletePassword(previous); err != nil {
+				// Nothing changed, so the rotation can simply be retried.
+				log.Err("Failed to remove %s: %v", previous, err)
+			}
+			return fmt.Errorf("failed to store new password: %w", err)
+		}
+
+		fmt.Fprintf(stdout, "Password rotated for %s. The previous value is available as %s until you run `chainenv rotate %s --finalize`.\n", account, previous, account)
+		return nil
+	},
+}
+
+// runRotateHook runs the hook command with the new value on stdin or in the
+// environment, depending on the hook's input setting.
+func runRotateHook(stderr io.Writer, hook *config.RotateConfig, account, provider, oldValue, newValue string) error {
+	c := exec.Command("sh", "-c", hook.Command)
+	c.Stdout = stderr
+	c.Stderr = stderr
+	c.Env = append(os.Environ(),
+		"CHAINENV_KEY="+account,
+		"CHAINENV_PROVIDER="+provider,
+	)
+
+	switch hook.Input {
+	case "", "stdin":
+		c.Stdin = strings.NewReader(newValue + "\n")
+	case "env":
+		c.Env = append(c.Env,
+			"CHAINENV_NEW_VALUE="+newValue,
+			"CHAINENV_OLD_VALUE="+oldValue,
+		)
+	default:
+		return fmt.Errorf("unknown hook input: %s (expected stdin or env)", hook.Input)
+	}
+
+	return c.Run()
+}
+
+func init() {
+	addGeneratePolicyFlags(rotateCmd)
+	rotateCmd.Flags().BoolVar(&rotatePrompt, "prompt", false, "Read the new value from stdin instead of generating it")
+	rotateCmd.Flags().StringVar(&rotateHook, "hook", "", "Pre-rotation command, overrides the configured hook")
+	rotateCmd.Flags().BoolVar(&rotateFinalize, "finalize", false, "Remove the previous value kept by the last rotation")
+	rootCmd.AddCommand(rotateCmd)
+}
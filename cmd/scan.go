@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/chainenv"
+	"github.com/dvcrn/chainenv/scan"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scanStaged      bool
+	scanHistory     bool
+	scanAllKeys     bool
+	scanJSON        bool
+	scanInstallHook bool
+	scanForce       bool
+)
+
+// hookMarker identifies pre-commit hooks installed by scan --install-hook.
+const hookMarker = "# Installed by chainenv scan --install-hook"
+
+const preCommitHook = "#!/bin/sh\n" + hookMarker + "\n" +
+	"# Refuses commits that add values of secrets managed by chainenv.\n" +
+	"exec chainenv scan --staged\n"
+
+var scanCmd = &cobra.Command{
+	Use:   "scan [paths...]",
+	Short: "Find leaked secrets in files or git history",
+	Long: `Search files for the values of the keys declared in config (or, with --all-keys, every key in the
+backend), raw as well as base64 and URL encoded. Matches are reported by file, line and key; values are
+never printed. Exits with a non-zero status if any secret is found.
+
+Without flags the given paths (default ".") are scanned recursively. --staged scans the lines added in
+the git index and --history the lines added by every commit. With either, paths limit the scan like a
+git pathspec. --install-hook installs a git pre-commit hook that runs "chainenv scan --staged", e.g.:
+  chainenv scan
+  chainenv scan --all-keys --backend 1password deploy/
+  chainenv scan --history
+  chainenv scan --install-hook`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
+		if scanInstallHook {
+			path, err := installPreCommitHook(scanForce)
+			if err != nil {
+				return fmt.Errorf("failed to install pre-commit hook: %w", err)
+			}
+			fmt.Fprintf(stderr, "Installed pre-commit hook at %s\n", path)
+			return nil
+		}
+		if scanStaged && scanHistory {
+			return errors.New("--staged and --history cannot be combined")
+		}
+
+		secrets, err := scanSecrets()
+		if err != nil {
+			return fmt.Errorf("error loading secrets: %w", err)
+		}
+		scanner := scan.New(secrets)
+		if scanner.Empty() {
+			fmt.Fprintf(stderr, "No secrets of at least %d characters to scan for\n", scan.MinLength)
+			return nil
+		}
+
+		var findings []scan.Finding
+		switch {
+		case scanStaged:
+			findings, err = scanGitDiff(scanner, []string{"diff", "--cached"}, args)
+		case scanHistory:
+			findings, err = scanGitDiff(scanner, []string{"log", "-p", "--all"}, args)
+		default:
+			if len(args) == 0 {
+				args = []string{"."}
+			}
+			for _, root := range args {
+				var found []scan.Finding
+				found, err = scanner.Tree(root)
+				findings = append(findings, found...)
+				if err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("scan failed: %w", err)
+		}
+
+		if scanJSON {
+			if findings == nil {
+				findings = []scan.Finding{}
+			}
+			enc := json.NewEncoder(stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(findings); err != nil {
+				return fmt.Errorf("error encoding findings: %w", err)
+			}
+		} else {
+			for _, f := range findings {
+				fmt.Fprintln(stdout, f)
+			}
+		}
+
+		if len(findings) > 0 {
+			if !scanJSON {
+				fmt.Fprintf(stderr, "Found %d secret occurrences\n", len(findings))
+			}
+			return exitCode(1)
+		}
+		return nil
+	},
+}
+
+// scanSecrets loads the values to scan for: every key in the backend with
+// --all-keys, otherwise the keys declared in config. Missing keys and
+// configured defaults are skipped, as they aren't secrets.
+func scanSecrets() (map[string]string, error) {
+	if scanAllKeys {
+		b, err := getBackendWithType(backendType)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := b.List()
+		if err != nil {
+			return nil, err
+		}
+		return readPasswords(b, keys)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return nil, errors.New("no config found, use --all-keys to scan for every key in the backend")
+	}
+
+	resolver := newResolver(cfg)
+	secrets := make(map[string]string)
+	for _, key := range chainenv.KeyNames(cfg) {
+		value, usedDefault, err := resolver.Resolve(key)
+		if errors.Is(err, backend.ErrNotFound) {
+			log.Debug("Skipping %s: not found", key)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		if !usedDefault {
+			secrets[key] = value
+		}
+	}
+	return secrets, nil
+}
+
+// scanGitDiff runs git with args and scans the added lines of the diff it
+// prints, limited to pathspecs.
+func scanGitDiff(scanner *scan.Scanner, args, pathspecs []string) ([]scan.Finding, error) {
+	args = append(args, "-U0", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", "--")
+	args = append(args, pathspecs...)
+
+	c := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+
+	findings, scanErr := scanner.Diff(stdout)
+	if err := c.Wait(); err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return findings, scanErr
+}
+
+// installPreCommitHook writes the pre-commit hook of the current repository.
+// An existing hook is only replaced if it was installed by chainenv or force
+// is set.
+func installPreCommitHook(force bool) (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks/pre-commit").Output()
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository: %v", err)
+	}
+	path := strings.TrimSpace(string(out))
+
+	existing, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if !force && !bytes.Contains(existing, []byte(hookMarker)) {
+			return "", fmt.Errorf("%s already exists, use --force to replace it", path)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(preCommitHook), 0o755); err != nil {
+		return "", err
+	}
+	// WriteFile keeps the mode of an existing file.
+	return path, os.Chmod(path, 0o755)
+}
+
+func init() {
+	scanCmd.Flags().BoolVar(&scanStaged, "staged", false, "Scan the changes staged for commit")
+	scanCmd.Flags().BoolVar(&scanHistory, "history", false, "Scan every commit in the git history")
+	scanCmd.Flags().BoolVar(&scanAllKeys, "all-keys", false, "Scan for every key in the backend instead of the keys in config")
+	scanCmd.Flags().BoolVar(&scanJSON, "json", false, "Output findings as JSON")
+	scanCmd.Flags().BoolVar(&scanInstallHook, "install-hook", false, "Install a git pre-commit hook running scan --staged")
+	scanCmd.Flags().BoolVar(&scanForce, "force", false, "Replace an existing pre-commit hook with --install-hook")
+	rootCmd.AddCommand(scanCmd)
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/dvcrn/chainenv/config"
 	"github.com/spf13/cobra"
@@ -15,58 +14,42 @@ var setCmd = &cobra.Command{
 	Short: "Set a password for an account",
 	Long:  `Store a new password for the specified account.`,
 	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stdout := cmd.OutOrStdout()
 		account := args[0]
 		password := args[1]
 		log.Debug("Setting password for account: %s", account)
 
 		b, err := getBackendWithType(backendType)
 		if err != nil {
-			log.Err("Error initializing backend: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("error initializing backend: %w", err)
 		}
 
 		if err := b.SetPassword(account, password, false); err != nil {
-			log.Err("Failed to set password: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("failed to set password: %w", err)
 		}
 
-		cwd, err := os.Getwd()
+		configPath, cfg, err := loadConfigForWrite()
 		if err != nil {
-			log.Err("Failed to determine current directory: %v", err)
-			os.Exit(1)
+			return err
 		}
 
-		configPath, ok, err := config.FindConfig(cwd)
-		if err != nil {
-			log.Err("Failed to locate config file: %v", err)
-			os.Exit(1)
-		}
-		if !ok {
-			configPath = config.DefaultConfigPath(cwd)
-		}
-
-		cfg, err := config.LoadOrEmpty(configPath)
-		if err != nil {
-			log.Err("Failed to read config: %v", err)
-			os.Exit(1)
-		}
-
-		entry := config.KeyEntry{Name: account, Provider: backendType}
+		entry := config.KeyEntry{Name: account}
 		if existing, ok := cfg.FindKey(account); ok {
-			entry.Default = existing.Default
+			entry = *existing
 		}
+		entry.Provider = backendType
 		if cmd.Flags().Changed("default") {
 			entry.Default = &setDefault
 		}
 		cfg.UpsertKey(entry)
 
 		if err := config.Save(configPath, cfg); err != nil {
-			log.Err("Failed to write config: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("failed to write config: %w", err)
 		}
 
-		fmt.Printf("Password set for %s\n", account)
+		fmt.Fprintf(stdout, "Password set for %s\n", account)
+		return nil
 	},
 }
 
@@ -75,23 +58,23 @@ var updateCmd = &cobra.Command{
 	Short: "Update a password for an existing account",
 	Long:  `Update the password for an existing account.`,
 	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stdout := cmd.OutOrStdout()
 		account := args[0]
 		password := args[1]
 		log.Debug("Updating password for account: %s", account)
 
 		b, err := getBackendWithType(backendType)
 		if err != nil {
-			log.Err("Error initializing backend: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("error initializing backend: %w", err)
 		}
 
 		if err := b.SetPassword(account, password, true); err != nil {
-			log.Err("Failed to update password: %v", err)
-			os.Exit(1)
+			return fmt.Errorf("failed to update password: %w", err)
 		}
 
-		fmt.Printf("Password updated for %s\n", account)
+		fmt.Fprintf(stdout, "Password updated for %s\n", account)
+		return nil
 	},
 }
 
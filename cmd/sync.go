@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/chainenv"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncFrom   string
+	syncTo     string
+	syncAll    bool
+	syncDryRun bool
+)
+
+type syncAction string
+
+const (
+	syncCreate    syncAction = "create"
+	syncUpdate    syncAction = "update"
+	syncUnchanged syncAction = "unchanged"
+	syncMissing   syncAction = "missing-in-source"
+)
+
+type syncStep struct {
+	Key    string
+	Action syncAction
+	value  string
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Synchronize passwords between backends",
+	Long: `Compare the keys declared in config (or every key in the source backend with --all)
+between two backends and make the target match the source, e.g.:
+  chainenv sync --from 1password --to keychain --dry-run
+  chainenv sync --from 1password --to keychain --all`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
+		if syncFrom == syncTo {
+			return fmt.Errorf("source and target backend are the same: %s", syncFrom)
+		}
+
+		sourceBackend, err := getBackendWithType(syncFrom)
+		if err != nil {
+			return fmt.Errorf("error initializing source backend: %w", err)
+		}
+
+		targetBackend, err := getBackendWithType(syncTo)
+		if err != nil {
+			return fmt.Errorf("error initializing target backend: %w", err)
+		}
+
+		var keys []string
+		if syncAll {
+			keys, err = sourceBackend.List()
+			if err != nil {
+				return fmt.Errorf("error listing source accounts: %w", err)
+			}
+		} else {
+			cfg, err := loadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			if cfg == nil {
+				fmt.Fprintln(stderr, "No config found. Use --all to sync every key in the source backend.")
+				return exitCode(1)
+			}
+			keys = chainenv.KeyNames(cfg)
+		}
+		if len(keys) == 0 {
+			fmt.Fprintln(stdout, "No keys found")
+			return nil
+		}
+
+		sourceValues, err := readPasswords(sourceBackend, keys)
+		if err != nil {
+			return fmt.Errorf("error reading from %s: %w", syncFrom, err)
+		}
+		targetValues, err := readPasswords(targetBackend, keys)
+		if err != nil {
+			return fmt.Errorf("error reading from %s: %w", syncTo, err)
+		}
+
+		plan := computeSyncPlan(keys, sourceValues, targetValues)
+		for _, step := range plan {
+			fmt.Fprintf(stdout, "%s %s (%s)\n", syncSymbol(step.Action), step.Key, step.Action)
+		}
+
+		if syncDryRun {
+			return nil
+		}
+
+		counts := make(map[syncAction]int)
+		failed := 0
+		for _, step := range plan {
+			var err error
+			switch step.Action {
+			case syncCreate:
+				err = targetBackend.SetPassword(step.Key, step.value, false)
+			case syncUpdate:
+				err = targetBackend.SetPassword(step.Key, step.value, true)
+			}
+			if err != nil {
+				log.Err("Failed to %s %s: %v", step.Action, step.Key, err)
+				failed++
+				continue
+			}
+			counts[step.Action]++
+		}
+
+		fmt.Fprintf(stdout, "Synced %s to %s: %d created, %d updated, %d unchanged, %d missing in source, %d failed\n",
+			syncFrom, syncTo, counts[syncCreate], counts[syncUpdate], counts[syncUnchanged], counts[syncMissing], failed)
+		if failed > 0 {
+			return exitCode(1)
+		}
+		return nil
+	},
+}
+
+// readPasswords fetches keys from b. Missing keys are absent from the result.
+// If the batch lookup fails, keys are looked up one by one so that a single
+// missing item doesn't fail the whole read.
+func readPasswords(b backend.Backend, keys []string) (map[string]string, error) {
+	values, err := b.GetMultiplePasswords(keys)
+	if err == nil {
+		return values, nil
+	}
+	log.Debug("Batch lookup failed, falling back to single lookups: %v", err)
+
+	values = make(map[string]string)
+	for _, key := range keys {
+		value, err := b.GetPassword(key)
+		if err != nil {
+			if errors.Is(err, backend.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// computeSyncPlan decides for every key what needs to happen in the target so
+// that it matches the source.
+func computeSyncPlan(keys []string, source, target map[string]string) []syncStep {
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+
+	var plan []syncStep
+	seen := make(map[string]bool)
+	for _, key := range sorted {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		step := syncStep{Key: key}
+		sourceValue, inSource := source[key]
+		targetValue, inTarget := target[key]
+		switch {
+		case !inSource:
+			step.Action = syncMissing
+		case !inTarget:
+			step.Action = syncCreate
+		case sourceValue != targetValue:
+			step.Action = syncUpdate
+		default:
+			step.Action = syncUnchanged
+		}
+		step.value = sourceValue
+		plan = append(plan, step)
+	}
+	return plan
+}
+
+func syncSymbol(action syncAction) string {
+	switch action {
+	case syncCreate:
+		return "+"
+	case syncUpdate:
+		return "~"
+	case syncMissing:
+		return "!"
+	default:
+		return "="
+	}
+}
+
+func init() {
+	syncCmd.Flags().StringVar(&syncFrom, "from", "", "Source backend (keychain or 1password)")
+	syncCmd.Flags().StringVar(&syncTo, "to", "", "Target backend (keychain or 1password)")
+	syncCmd.Flags().BoolVar(&syncAll, "all", false, "Sync every key in the source backend instead of the keys in config")
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Only show the plan without applying it")
+	syncCmd.MarkFlagRequired("from")
+	syncCmd.MarkFlagRequired("to")
+	rootCmd.AddCommand(syncCmd)
+}
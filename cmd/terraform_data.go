@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dvcrn/chainenv/chainenv"
+	"github.com/spf13/cobra"
+)
+
+// terraformKeysAttr is the query attribute listing keys to return under their
+// own names.
+const terraformKeysAttr = "keys"
+
+var terraformDataCmd = &cobra.Command{
+	Use:   "terraform-data",
+	Short: "Resolve secrets for a Terraform external data source",
+	Long: `Read the query of a Terraform external data source from stdin and print the resolved secrets as a
+flat JSON object. The "keys" attribute lists keys, comma-separated, returned under their own names; any
+other attribute returns the key it names under the attribute's name. An empty query returns every key
+declared in config. Any missing key, or two keys returned under the same name, fails the data source, e.g.:
+  data "external" "secrets" {
+    program = ["chainenv", "terraform-data"]
+    query = {
+      keys       = "DB_PASSWORD,API_TOKEN"
+      github_pat = "GITHUB_TOKEN"
+    }
+  }`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
+		var query map[string]string
+		if err := json.NewDecoder(cmd.InOrStdin()).Decode(&query); err != nil {
+			return fmt.Errorf("invalid query, expected a JSON object of strings: %w", err)
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+
+		names, err := terraformResultNames(query)
+		if err != nil {
+			return fmt.Errorf("invalid query: %w", err)
+		}
+		if len(names) == 0 {
+			if cfg == nil {
+				fmt.Fprintln(stderr, "No config found")
+				return exitCode(1)
+			}
+			for _, key := range chainenv.KeyNames(cfg) {
+				names[key] = key
+			}
+		}
+
+		accounts := make([]string, 0, len(names))
+		seen := make(map[string]bool)
+		for _, key := range names {
+			if !seen[key] {
+				seen[key] = true
+				accounts = append(accounts, key)
+			}
+		}
+		sort.Strings(accounts)
+
+		passwords, firstErr := newResolver(cfg).ResolveAll(accounts)
+		if firstErr != nil {
+			fmt.Fprintln(stderr, firstErr.Error())
+			return exitCode(1)
+		}
+
+		result := make(map[string]string, len(names))
+		for name, key := range names {
+			result[name] = passwords[key]
+		}
+		if err := json.NewEncoder(stdout).Encode(result); err != nil {
+			return fmt.Errorf("error encoding result: %w", err)
+		}
+		return nil
+	},
+}
+
+// terraformResultNames maps each attribute of the result to the key holding
+// its value. It fails if an attribute would hold two different keys, e.g. for
+// keys = "A" together with A = "B".
+func terraformResultNames(query map[string]string) (map[string]string, error) {
+	names := make(map[string]string)
+	add := func(name, key string) error {
+		if other, ok := names[name]; ok && other != key {
+			first, second := other, key
+			if first > second {
+				first, second = second, first
+			}
+			return fmt.Errorf("result attribute %s would hold both %s and %s", name, first, second)
+		}
+		names[name] = key
+		return nil
+	}
+	for attr, value := range query {
+		if attr != terraformKeysAttr {
+			if err := add(attr, value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, key := range strings.Split(value, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				if err := add(key, key); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+func init() {
+	rootCmd.AddCommand(terraformDataCmd)
+}
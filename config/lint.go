@@ -0,0 +1,172 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// Providers lists the provider names accepted in key entries.
+var Providers = []string{"keychain", "1password"}
+
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Issue is a problem found while linting a config file. Line is 1-based and
+// zero when the location is unknown.
+type Issue struct {
+	Line    int    `json:"line,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+	}
+	return i.Message
+}
+
+// LintFile reads and lints the config at path.
+func LintFile(path string) ([]Issue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Lint(data), nil
+}
+
+// Lint checks a config document for syntax errors, unknown fields, key
+// entries with empty, duplicate or invalid names, unknown providers or invalid
+// generation policies, and incomplete git credential entries.
+func Lint(data []byte) []Issue {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	var issues []Issue
+	var cfg Config
+	dec := toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		var strictErr *toml.StrictMissingError
+		var decodeErr *toml.DecodeError
+		switch {
+		case errors.As(err, &strictErr):
+			for _, e := range strictErr.Errors {
+				line, _ := e.Position()
+				key := strings.Join(e.Key(), ".")
+				issues = append(issues, Issue{Line: line, Key: key, Message: fmt.Sprintf("unknown field %q", key)})
+			}
+		case errors.As(err, &decodeErr):
+			line, _ := decodeErr.Position()
+			return []Issue{{Line: line, Message: decodeErr.Error()}}
+		default:
+			return []Issue{{Message: err.Error()}}
+		}
+	}
+
+	lines := keyEntryLines(data)
+	lineOf := func(i int) int {
+		if i < len(lines) {
+			return lines[i]
+		}
+		return 0
+	}
+
+	seen := make(map[string]int)
+	for i, entry := range cfg.Keys {
+		line := lineOf(i)
+		switch {
+		case entry.Name == "":
+			issues = append(issues, Issue{Line: line, Message: "key entry has no name"})
+		case !envNameRegex.MatchString(entry.Name):
+			issues = append(issues, Issue{Line: line, Key: entry.Name, Message: fmt.Sprintf("%q is not a valid environment variable name", entry.Name)})
+		}
+
+		if entry.Name != "" {
+			if first, ok := seen[entry.Name]; ok {
+				msg := fmt.Sprintf("duplicate key %q", entry.Name)
+				if first > 0 {
+					msg += fmt.Sprintf(" (first declared on line %d)", first)
+				}
+				issues = append(issues, Issue{Line: line, Key: entry.Name, Message: msg})
+			} else {
+				seen[entry.Name] = line
+			}
+		}
+
+		if entry.Provider != "" && !slices.Contains(Providers, entry.Provider) {
+			issues = append(issues, Issue{Line: line, Key: entry.Name, Message: fmt.Sprintf("unknown provider %q (expected one of %s)", entry.Provider, strings.Join(Providers, ", "))})
+		}
+
+		if entry.Generate != nil {
+			if err := entry.Generate.Validate(); err != nil {
+				issues = append(issues, Issue{Line: line, Key: entry.Name, Message: fmt.Sprintf("invalid generate policy: %v", err)})
+			}
+		}
+
+		if entry.Rotate != nil {
+			switch entry.Rotate.Input {
+			case "", "stdin", "env":
+			default:
+				issues = append(issues, Issue{Line: line, Key: entry.Name, Message: fmt.Sprintf("invalid rotate input %q (expected stdin or env)", entry.Rotate.Input)})
+			}
+		}
+	}
+
+	for _, entry := range cfg.GitCredentials {
+		switch {
+		case entry.Host == "":
+			issues = append(issues, Issue{Message: "git credential entry has no host"})
+		case entry.Key == "":
+			issues = append(issues, Issue{Message: fmt.Sprintf("git credential entry for %q has no key", entry.Host)})
+		case !envNameRegex.MatchString(entry.Key):
+			issues = append(issues, Issue{Key: entry.Key, Message: fmt.Sprintf("git credential entry for %q: %q is not a valid key name", entry.Host, entry.Key)})
+		}
+		if entry.Provider != "" && !slices.Contains(Providers, entry.Provider) {
+			issues = append(issues, Issue{Key: entry.Key, Message: fmt.Sprintf("git credential entry for %q: unknown provider %q (expected one of %s)", entry.Host, entry.Provider, strings.Join(Providers, ", "))})
+		}
+	}
+
+	return issues
+}
+
+// keyEntryLines returns the line of each [[keys]] entry in document order.
+func keyEntryLines(data []byte) []int {
+	var lines []int
+	p := &unstable.Parser{}
+	p.Reset(data)
+	for p.NextExpression() {
+		expr := p.Expression()
+		if expr.Kind != unstable.ArrayTable {
+			continue
+		}
+
+		var path []string
+		var first *unstable.Node
+		it := expr.Children()
+		for it.Next() {
+			n := it.Node()
+			if first == nil {
+				first = n
+			}
+			path = append(path, string(n.Data))
+		}
+		if len(path) == 1 && path[0] == "keys" && first != nil {
+			lines = append(lines, p.Shape(first.Raw).Start.Line)
+		}
+	}
+	return lines
+}
+
+// Schema is the JSON Schema describing the config file, for editor validation.
+//
+//go:embed chainenv.schema.json
+var Schema []byte
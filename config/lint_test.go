@@ -0,0 +1,116 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dvcrn/chainenv/secretgen"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`["1password"]
+service_account_token_key = "TOKEN"
+
+[[keys]]
+name = "GITHUB_TOKEN"
+provider = "keychain"
+
+[[keys]]
+name = "GITHUB_TOKEN"
+
+[[keys]]
+provider = "vault"
+
+[[keys]]
+name = "not-valid"
+defualt = "x"
+
+[[keys]]
+name = "DB_PASSWORD"
+
+[keys.generate]
+mode = "bogus"
+`)
+
+	issues := Lint(data)
+
+	want := []struct {
+		line int
+		msg  string
+	}{
+		{16, `unknown field "keys.defualt"`},
+		{8, `duplicate key "GITHUB_TOKEN" (first declared on line 4)`},
+		{11, "key entry has no name"},
+		{11, `unknown provider "vault"`},
+		{14, `"not-valid" is not a valid environment variable name`},
+		{18, "invalid generate policy: unknown mode: bogus"},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("expected %d issues, got %d: %v", len(want), len(issues), issues)
+	}
+	for i, w := range want {
+		if issues[i].Line != w.line || !strings.HasPrefix(issues[i].Message, w.msg) {
+			t.Errorf("issue %d: expected line %d %q, got %v", i, w.line, w.msg, issues[i])
+		}
+	}
+}
+
+func TestLintSyntaxError(t *testing.T) {
+	t.Parallel()
+
+	issues := Lint([]byte("[[keys]]\nname = \n"))
+	if len(issues) != 1 || issues[0].Line != 2 {
+		t.Fatalf("expected a single issue on line 2, got %v", issues)
+	}
+}
+
+func TestLintValid(t *testing.T) {
+	t.Parallel()
+
+	issues := Lint([]byte(`
+[[keys]]
+name = "FOO"
+provider = "1password"
+default = "bar"
+`))
+	if len(issues) != 0 {
+		t.Fatalf("expected no issues, got %v", issues)
+	}
+}
+
+func TestSchemaMatchesKeyEntry(t *testing.T) {
+	t.Parallel()
+
+	var schema struct {
+		Definitions map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatalf("unmarshal schema: %v", err)
+	}
+
+	check := func(def string, v any) {
+		t.Helper()
+		typ := reflect.TypeOf(v)
+		props := schema.Definitions[def].Properties
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("toml"), ",")
+			if _, ok := props[name]; !ok {
+				t.Errorf("schema definition %q is missing field %q", def, name)
+			}
+		}
+		if len(props) != typ.NumField() {
+			t.Errorf("schema definition %q has %d properties, struct has %d fields", def, len(props), typ.NumField())
+		}
+	}
+
+	check("key", KeyEntry{})
+	check("generate", secretgen.Policy{})
+	check("rotate", RotateConfig{})
+	check("git_credential", GitCredential{})
+}
@@ -2,22 +2,53 @@ package config
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 
+	"github.com/dvcrn/chainenv/fsutil"
+	"github.com/dvcrn/chainenv/secretgen"
 	"github.com/pelletier/go-toml/v2"
 )
 
 type Config struct {
-	Keys        []KeyEntry         `toml:"keys"`
-	OnePassword *OnePasswordConfig `toml:"1password,omitempty"`
+	Keys           []KeyEntry         `toml:"keys"`
+	GitCredentials []GitCredential    `toml:"git_credentials,omitempty"`
+	OnePassword    *OnePasswordConfig `toml:"1password,omitempty"`
 }
 
 type KeyEntry struct {
-	Name     string  `toml:"name"`
-	Provider string  `toml:"provider,omitempty"`
-	Default  *string `toml:"default,omitempty"`
+	Name     string            `toml:"name"`
+	Provider string            `toml:"provider,omitempty"`
+	Default  *string           `toml:"default,omitempty"`
+	File     bool              `toml:"file,omitempty"`
+	Generate *secretgen.Policy `toml:"generate,omitempty"`
+	Rotate   *RotateConfig     `toml:"rotate,omitempty"`
+}
+
+// RotateConfig configures the hook run by `chainenv rotate` before a new
+// value is committed.
+type RotateConfig struct {
+	// Command is run with `sh -c` and must exit 0 for the rotation to proceed.
+	Command string `toml:"command,omitempty"`
+	// Input selects how the new value is passed to Command: "stdin" (default)
+	// or "env".
+	Input string `toml:"input,omitempty"`
+}
+
+// GitCredential maps the credentials git asks for on a host to the key
+// holding the password or token, for `chainenv git-credential`.
+type GitCredential struct {
+	// Host as sent by git, including the port if any.
+	Host string `toml:"host"`
+	// Protocol and Username restrict the entry; empty matches any.
+	Protocol string `toml:"protocol,omitempty"`
+	Username string `toml:"username,omitempty"`
+	// Key holds the password and Provider the backend storing it. An empty
+	// Provider falls back to the provider configured for Key.
+	Key      string `toml:"key"`
+	Provider string `toml:"provider,omitempty"`
 }
 
 type OnePasswordConfig struct {
@@ -58,7 +89,7 @@ func Save(path string, cfg *Config) error {
 		data = append(data, '\n')
 	}
 
-	return atomicWriteFile(path, data, 0o644)
+	return fsutil.WriteFileAtomic(path, data, 0o644)
 }
 
 func (c *Config) FindKey(name string) (*KeyEntry, bool) {
@@ -80,40 +111,47 @@ func (c *Config) UpsertKey(entry KeyEntry) {
 	c.Keys = append(c.Keys, entry)
 }
 
+// FindGitCredential returns the first git credential entry matching the
+// protocol, host and username of a request. An empty username matches any
+// entry for the host.
+func (c *Config) FindGitCredential(protocol, host, username string) (*GitCredential, bool) {
+	for i := range c.GitCredentials {
+		entry := &c.GitCredentials[i]
+		if !strings.EqualFold(entry.Host, host) {
+			continue
+		}
+		if entry.Protocol != "" && entry.Protocol != protocol {
+			continue
+		}
+		if entry.Username != "" && username != "" && entry.Username != username {
+			continue
+		}
+		return entry, true
+	}
+	return nil, false
+}
+
 func parseConfig(data []byte) (*Config, error) {
 	if len(bytes.TrimSpace(data)) == 0 {
 		return &Config{}, nil
 	}
 
+	// Unknown fields are rejected so that typos don't silently disable
+	// settings. `chainenv config lint` lists all of them.
 	var cfg Config
-	if err := toml.Unmarshal(data, &cfg); err != nil {
+	dec := toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		var strictErr *toml.StrictMissingError
+		if errors.As(err, &strictErr) && len(strictErr.Errors) > 0 {
+			e := strictErr.Errors[0]
+			line, _ := e.Position()
+			msg := fmt.Sprintf("line %d: unknown field %q", line, strings.Join(e.Key(), "."))
+			if n := len(strictErr.Errors) - 1; n > 0 {
+				msg += fmt.Sprintf(" (and %d more, see `chainenv config lint`)", n)
+			}
+			return nil, fmt.Errorf("parse config: %s", msg)
+		}
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
 	return &cfg, nil
 }
-
-func atomicWriteFile(path string, data []byte, perm os.FileMode) error {
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return err
-	}
-
-	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
-	if err != nil {
-		return err
-	}
-	defer os.Remove(tmp.Name())
-
-	if _, err := tmp.Write(data); err != nil {
-		tmp.Close()
-		return err
-	}
-	if err := tmp.Close(); err != nil {
-		return err
-	}
-	if err := os.Chmod(tmp.Name(), perm); err != nil {
-		return err
-	}
-
-	return os.Rename(tmp.Name(), path)
-}
@@ -4,7 +4,10 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/dvcrn/chainenv/secretgen"
 )
 
 func TestSaveLoadRoundTrip(t *testing.T) {
@@ -101,3 +104,96 @@ service_account_token_key = "CHAINENV_OP_TOKEN"
 		t.Fatalf("unexpected token key: %s", cfg.OnePassword.ServiceAccountTokenKey)
 	}
 }
+
+func TestLoadGeneratePolicy(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`
+[[keys]]
+name = "DB_PASSWORD"
+provider = "keychain"
+
+[keys.generate]
+mode = "passphrase"
+words = 6
+separator = "_"
+`)
+	cfg, err := parseConfig(data)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	entry, ok := cfg.FindKey("DB_PASSWORD")
+	if !ok || entry.Generate == nil {
+		t.Fatalf("expected generate policy, got %#v", entry)
+	}
+	want := secretgen.Policy{Mode: "passphrase", Words: 6, Separator: "_"}
+	if *entry.Generate != want {
+		t.Fatalf("unexpected policy: %#v", *entry.Generate)
+	}
+}
+
+func TestFindGitCredential(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := parseConfig([]byte(`
+[[git_credentials]]
+host = "git.example.com"
+username = "deploy"
+key = "GIT_DEPLOY_TOKEN"
+
+[[git_credentials]]
+host = "git.example.com"
+protocol = "https"
+key = "GIT_TOKEN"
+provider = "1password"
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tests := []struct {
+		protocol, host, username string
+		want                     string
+	}{
+		{"https", "git.example.com", "deploy", "GIT_DEPLOY_TOKEN"},
+		{"https", "git.example.com", "", "GIT_DEPLOY_TOKEN"},
+		{"https", "GIT.example.com", "someone", "GIT_TOKEN"},
+		{"ssh", "git.example.com", "someone", ""},
+		{"https", "git.example.com:8443", "someone", ""},
+	}
+	for _, tt := range tests {
+		entry, ok := cfg.FindGitCredential(tt.protocol, tt.host, tt.username)
+		got := ""
+		if ok {
+			got = entry.Key
+		}
+		if got != tt.want {
+			t.Errorf("FindGitCredential(%q, %q, %q) = %q, want %q", tt.protocol, tt.host, tt.username, got, tt.want)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), DotConfigName)
+	data := `
+[[keys]]
+name = "API_TOKEN"
+defualt = "x"
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("Load accepted a config with an unknown field")
+	}
+	if want := `line 4: unknown field "keys.defualt"`; !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, want it to contain %s", err, want)
+	}
+	if _, err := LoadOrEmpty(path); err == nil {
+		t.Error("LoadOrEmpty accepted a config with an unknown field")
+	}
+}
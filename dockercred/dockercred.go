@@ -0,0 +1,149 @@
+// Package dockercred implements Docker's credential helper protocol on top of
+// a chainenv backend.
+package dockercred
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dvcrn/chainenv/backend"
+)
+
+// NotFoundMessage is printed when no credentials exist for a server. Docker
+// recognizes this exact message.
+const NotFoundMessage = "credentials not found in native keychain"
+
+// KeyPrefix is prepended to the encoded server URL to form the key
+// credentials are stored under.
+const KeyPrefix = "docker-credential-"
+
+// ErrNotFound is returned by Serve when no credentials exist for a server.
+var ErrNotFound = errors.New(NotFoundMessage)
+
+// Credentials are the registry credentials exchanged with Docker.
+type Credentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// storedValue is what is stored in the backend for each server.
+type storedValue struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// Key returns the key credentials for serverURL are stored under. Server URLs
+// contain slashes, which 1Password doesn't allow in item references, so the
+// URL is base64url-encoded.
+func Key(serverURL string) string {
+	return KeyPrefix + base64.RawURLEncoding.EncodeToString([]byte(serverURL))
+}
+
+// Serve runs action ("get", "store", "erase" or "list") against b, reading
+// the request from in and writing the response to out.
+func Serve(b backend.Backend, action string, in io.Reader, out io.Writer) error {
+	switch action {
+	case "get":
+		serverURL, err := readServerURL(in)
+		if err != nil {
+			return err
+		}
+		value, err := b.GetPassword(Key(serverURL))
+		if errors.Is(err, backend.ErrNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var stored storedValue
+		if err := json.Unmarshal([]byte(value), &stored); err != nil {
+			return fmt.Errorf("invalid stored credentials for %s: %w", serverURL, err)
+		}
+		return json.NewEncoder(out).Encode(Credentials{ServerURL: serverURL, Username: stored.Username, Secret: stored.Secret})
+
+	case "store":
+		var creds Credentials
+		if err := json.NewDecoder(in).Decode(&creds); err != nil {
+			return fmt.Errorf("invalid credentials: %w", err)
+		}
+		if creds.ServerURL == "" {
+			return errors.New("no server URL given")
+		}
+		value, err := json.Marshal(storedValue{ServerURL: creds.ServerURL, Username: creds.Username, Secret: creds.Secret})
+		if err != nil {
+			return err
+		}
+		key := Key(creds.ServerURL)
+		_, err = b.GetPassword(key)
+		switch {
+		case err == nil:
+			return b.SetPassword(key, string(value), true)
+		case errors.Is(err, backend.ErrNotFound):
+			return b.SetPassword(key, string(value), false)
+		default:
+			return err
+		}
+
+	case "erase":
+		serverURL, err := readServerURL(in)
+		if err != nil {
+			return err
+		}
+		err = b.DeletePassword(Key(serverURL))
+		if errors.Is(err, backend.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+
+	case "list":
+		keys, err := b.List()
+		if err != nil {
+			return err
+		}
+		var ours []string
+		for _, key := range keys {
+			if strings.HasPrefix(key, KeyPrefix) {
+				ours = append(ours, key)
+			}
+		}
+		list := make(map[string]string)
+		if len(ours) > 0 {
+			values, err := b.GetMultiplePasswords(ours)
+			if err != nil {
+				return err
+			}
+			for _, value := range values {
+				var stored storedValue
+				if err := json.Unmarshal([]byte(value), &stored); err != nil {
+					continue
+				}
+				if stored.ServerURL == "" {
+					continue
+				}
+				list[stored.ServerURL] = stored.Username
+			}
+		}
+		return json.NewEncoder(out).Encode(list)
+
+	default:
+		return fmt.Errorf("unknown credential action: %s", action)
+	}
+}
+
+func readServerURL(in io.Reader) (string, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return "", err
+	}
+	serverURL := strings.TrimSpace(string(data))
+	if serverURL == "" {
+		return "", errors.New("no server URL given")
+	}
+	return serverURL, nil
+}
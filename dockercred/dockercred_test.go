@@ -0,0 +1,135 @@
+package dockercred
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dvcrn/chainenv/backend"
+	"github.com/dvcrn/chainenv/internal/fakeop"
+)
+
+// strictBackend is an in-memory backend that fails unless SetPassword is
+// asked to update exactly the accounts that exist.
+type strictBackend struct {
+	*backend.MemoryBackend
+}
+
+func newStrictBackend(passwords map[string]string) strictBackend {
+	return strictBackend{backend.NewMemoryBackend(passwords)}
+}
+
+func (b strictBackend) SetPassword(account, password string, update bool) error {
+	_, err := b.GetPassword(account)
+	if exists := err == nil; exists != update {
+		return fmt.Errorf("update = %v for %s, but exists = %v", update, account, exists)
+	}
+	return b.MemoryBackend.SetPassword(account, password, update)
+}
+
+func serve(t *testing.T, b backend.Backend, action, input string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := Serve(b, action, strings.NewReader(input), &out)
+	return strings.TrimSpace(out.String()), err
+}
+
+func TestStoreGetListErase(t *testing.T) {
+	t.Parallel()
+
+	b := newStrictBackend(map[string]string{"GITHUB_TOKEN": "unrelated"})
+
+	for _, secret := range []string{"first", "second"} {
+		input := `{"ServerURL":"https://ghcr.io","Username":"octocat","Secret":"` + secret + `"}`
+		if _, err := serve(t, b, "store", input); err != nil {
+			t.Fatalf("store: %v", err)
+		}
+	}
+
+	out, err := serve(t, b, "get", "https://ghcr.io\n")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if want := `{"ServerURL":"https://ghcr.io","Username":"octocat","Secret":"second"}`; out != want {
+		t.Fatalf("get = %s, want %s", out, want)
+	}
+
+	out, err = serve(t, b, "list", "")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if want := `{"https://ghcr.io":"octocat"}`; out != want {
+		t.Fatalf("list = %s, want %s", out, want)
+	}
+
+	if _, err := serve(t, b, "erase", "https://ghcr.io"); err != nil {
+		t.Fatalf("erase: %v", err)
+	}
+	if _, err := serve(t, b, "get", "https://ghcr.io"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get after erase: expected ErrNotFound, got %v", err)
+	}
+	if _, err := b.GetPassword("GITHUB_TOKEN"); err != nil {
+		t.Fatal("unrelated key was removed")
+	}
+}
+
+func TestOnePassword(t *testing.T) {
+	f := fakeop.Start(t)
+	b := backend.NewOnePasswordBackend("chainenv")
+
+	servers := []string{"https://index.docker.io/v1/", "ghcr.io"}
+	for _, server := range servers {
+		for _, secret := range []string{"first", "second"} {
+			input := `{"ServerURL":"` + server + `","Username":"octocat","Secret":"` + secret + `"}`
+			if _, err := serve(t, b, "store", input); err != nil {
+				t.Fatalf("store %s: %v", server, err)
+			}
+		}
+		out, err := serve(t, b, "get", server)
+		if err != nil {
+			t.Fatalf("get %s: %v", server, err)
+		}
+		if want := `{"ServerURL":"` + server + `","Username":"octocat","Secret":"second"}`; out != want {
+			t.Fatalf("get = %s, want %s", out, want)
+		}
+	}
+	for _, item := range f.State().Items {
+		if strings.Contains(item.Title, "/") {
+			t.Errorf("item title %q contains a slash", item.Title)
+		}
+	}
+
+	out, err := serve(t, b, "list", "")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if want := `{"ghcr.io":"octocat","https://index.docker.io/v1/":"octocat"}`; out != want {
+		t.Fatalf("list = %s, want %s", out, want)
+	}
+
+	if _, err := serve(t, b, "erase", servers[0]); err != nil {
+		t.Fatalf("erase: %v", err)
+	}
+	if _, err := serve(t, b, "get", servers[0]); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get after erase: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestListEmpty(t *testing.T) {
+	t.Parallel()
+
+	out, err := serve(t, newStrictBackend(nil), "list", "")
+	if err != nil || out != "{}" {
+		t.Fatalf("list = %q, %v; want {}", out, err)
+	}
+}
+
+func TestUnknownAction(t *testing.T) {
+	t.Parallel()
+
+	if _, err := serve(t, newStrictBackend(nil), "bogus", ""); err == nil {
+		t.Fatal("expected an error for an unknown action")
+	}
+}
@@ -0,0 +1,137 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	FormatDotenv    = "dotenv"
+	FormatJSON      = "json"
+	FormatYAML      = "yaml"
+	FormatSecret    = "k8s-secret"
+	FormatConfigMap = "k8s-configmap"
+)
+
+// Formats lists all supported output formats.
+var Formats = []string{FormatDotenv, FormatJSON, FormatYAML, FormatSecret, FormatConfigMap}
+
+// Options controls format specific output.
+type Options struct {
+	// Name is the metadata.name of generated Kubernetes manifests.
+	Name string
+	// Namespace is the optional metadata.namespace of generated Kubernetes manifests.
+	Namespace string
+}
+
+// Render encodes values in the given format. Keys are always emitted in
+// sorted order so that the output is stable across runs.
+func Render(format string, values map[string]string, opts Options) ([]byte, error) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	switch format {
+	case FormatDotenv:
+		return renderDotenv(keys, values), nil
+	case FormatJSON:
+		return renderJSON(values)
+	case FormatYAML:
+		return renderYAML(keys, values), nil
+	case FormatSecret, FormatConfigMap:
+		if opts.Name == "" {
+			return nil, fmt.Errorf("a name is required for %s output", format)
+		}
+		return renderManifest(format, keys, values, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown format: %s (supported: %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+var dotenvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+
+// renderDotenv single-quotes values where possible, because docker compose
+// and python-dotenv expand variables like $VAR in double-quoted values, which
+// would corrupt secrets containing "$". Values with quotes, backslashes
+// (which python-dotenv unescapes even in single quotes) or line breaks are
+// double-quoted with "$" escaped instead.
+func renderDotenv(keys []string, values map[string]string) []byte {
+	var buf bytes.Buffer
+	for _, k := range keys {
+		v := values[k]
+		if strings.ContainsAny(v, "'\\\n\r") {
+			fmt.Fprintf(&buf, "%s=\"%s\"\n", k, dotenvEscaper.Replace(v))
+		} else {
+			fmt.Fprintf(&buf, "%s='%s'\n", k, v)
+		}
+	}
+	return buf.Bytes()
+}
+
+func renderJSON(values map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(values); err != nil {
+		return nil, fmt.Errorf("encode json: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func renderYAML(keys []string, values map[string]string) []byte {
+	var buf bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s: %s\n", yamlString(k), yamlString(values[k]))
+	}
+	return buf.Bytes()
+}
+
+func renderManifest(format string, keys []string, values map[string]string, opts Options) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("apiVersion: v1\n")
+	if format == FormatSecret {
+		buf.WriteString("kind: Secret\n")
+	} else {
+		buf.WriteString("kind: ConfigMap\n")
+	}
+	buf.WriteString("metadata:\n")
+	fmt.Fprintf(&buf, "  name: %s\n", yamlString(opts.Name))
+	if opts.Namespace != "" {
+		fmt.Fprintf(&buf, "  namespace: %s\n", yamlString(opts.Namespace))
+	}
+	if format == FormatSecret {
+		buf.WriteString("type: Opaque\n")
+	}
+	if len(keys) == 0 {
+		buf.WriteString("data: {}\n")
+		return buf.Bytes()
+	}
+
+	buf.WriteString("data:\n")
+	for _, k := range keys {
+		v := values[k]
+		if format == FormatSecret {
+			v = base64.StdEncoding.EncodeToString([]byte(v))
+		}
+		fmt.Fprintf(&buf, "  %s: %s\n", yamlString(k), yamlString(v))
+	}
+	return buf.Bytes()
+}
+
+// yamlString quotes s as a YAML double-quoted scalar. JSON strings are valid
+// YAML, which saves us from dealing with YAML's implicit typing rules.
+func yamlString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// Encoding a string cannot fail.
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
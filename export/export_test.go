@@ -0,0 +1,101 @@
+package export
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRenderDotenv(t *testing.T) {
+	t.Parallel()
+
+	out, err := Render(FormatDotenv, map[string]string{
+		"B": "multi\nline",
+		"A": `quote " and \ backslash`,
+		"C": "pa$$w0rd$HOME${USER}",
+		"D": "it's $HOME\n",
+	}, Options{})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	want := "A=\"quote \\\" and \\\\ backslash\"\nB=\"multi\\nline\"\n" +
+		"C='pa$$w0rd$HOME${USER}'\n" +
+		"D=\"it's \\$HOME\\n\"\n"
+	if string(out) != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]string{"TOKEN": "a<b>&c"}
+	out, err := Render(FormatJSON, values, Options{})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(string(out), "a<b>&c") {
+		t.Fatalf("expected unescaped value, got %s", out)
+	}
+
+	var decoded map[string]string
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decoded["TOKEN"] != values["TOKEN"] {
+		t.Fatalf("round-trip mismatch: %q", decoded["TOKEN"])
+	}
+}
+
+func TestRenderYAML(t *testing.T) {
+	t.Parallel()
+
+	out, err := Render(FormatYAML, map[string]string{"FLAG": "true", "EMPTY": ""}, Options{})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	want := "\"EMPTY\": \"\"\n\"FLAG\": \"true\"\n"
+	if string(out) != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestRenderSecret(t *testing.T) {
+	t.Parallel()
+
+	out, err := Render(FormatSecret, map[string]string{"TOKEN": "secret"}, Options{Name: "app", Namespace: "prod"})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	want := `apiVersion: v1
+kind: Secret
+metadata:
+  name: "app"
+  namespace: "prod"
+type: Opaque
+data:
+  "TOKEN": "c2VjcmV0"
+`
+	if string(out) != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestRenderConfigMapRequiresName(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Render(FormatConfigMap, map[string]string{"A": "b"}, Options{}); err == nil {
+		t.Fatalf("expected error without name")
+	}
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Render("xml", nil, Options{}); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
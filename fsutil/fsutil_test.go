@@ -0,0 +1,65 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nested", "secret.env")
+	if err := WriteFileAtomic(path, []byte("A=1\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := WriteFileAtomic(path, []byte("A=2\n"), 0o600); err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(data) != "A=2\n" {
+		t.Fatalf("unexpected content %q", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("expected 0600, got %v", perm)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("readdir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected temporary files to be cleaned up, got %d entries", len(entries))
+	}
+}
+
+func TestMkdirPrivate(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "private")
+	if err := MkdirPrivate(dir); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := MkdirPrivate(dir); err != nil {
+		t.Fatalf("existing private dir: %v", err)
+	}
+
+	open := filepath.Join(t.TempDir(), "open")
+	if err := os.Mkdir(open, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.Chmod(open, 0o755); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	if err := MkdirPrivate(open); err == nil {
+		t.Fatalf("expected error for world readable dir")
+	}
+}
@@ -0,0 +1,87 @@
+// Package gitcredential implements the input and output format of git
+// credential helpers: key=value lines terminated by a blank line or EOF.
+package gitcredential
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Credential holds the attributes of a credential request or response that
+// chainenv uses. Other attributes are ignored.
+type Credential struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+	Password string
+}
+
+// Read parses a credential description from r. A url attribute is broken up
+// into its parts, which later attributes may override.
+func Read(r io.Reader) (Credential, error) {
+	var c Credential
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return Credential{}, fmt.Errorf("invalid line %q", line)
+		}
+		switch key {
+		case "protocol":
+			c.Protocol = value
+		case "host":
+			c.Host = value
+		case "path":
+			c.Path = value
+		case "username":
+			c.Username = value
+		case "password":
+			c.Password = value
+		case "url":
+			u, err := url.Parse(value)
+			if err != nil {
+				return Credential{}, fmt.Errorf("invalid url: %w", err)
+			}
+			c.Protocol = u.Scheme
+			c.Host = u.Host
+			c.Path = strings.TrimPrefix(u.Path, "/")
+			if u.User != nil {
+				c.Username = u.User.Username()
+				c.Password, _ = u.User.Password()
+			}
+		}
+	}
+	return c, scanner.Err()
+}
+
+// Write writes the non-empty attributes of c to w. Values must not contain
+// newlines, as git would be unable to parse them.
+func Write(w io.Writer, c Credential) error {
+	attrs := []struct{ key, value string }{
+		{"protocol", c.Protocol},
+		{"host", c.Host},
+		{"path", c.Path},
+		{"username", c.Username},
+		{"password", c.Password},
+	}
+	for _, a := range attrs {
+		if a.value == "" {
+			continue
+		}
+		if strings.ContainsAny(a.value, "\n\x00") {
+			return fmt.Errorf("%s contains a newline or NUL byte", a.key)
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", a.key, a.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package gitcredential
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	input := "protocol=https\nhost=git.example.com:8443\nusername=deploy\nwwwauth[]=Basic realm=\"x\"\n\nignored=after blank line\n"
+	c, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := Credential{Protocol: "https", Host: "git.example.com:8443", Username: "deploy"}
+	if c != want {
+		t.Fatalf("Read = %+v, want %+v", c, want)
+	}
+}
+
+func TestReadURL(t *testing.T) {
+	t.Parallel()
+
+	c, err := Read(strings.NewReader("url=https://deploy@git.example.com/org/repo.git\nusername=other"))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := Credential{Protocol: "https", Host: "git.example.com", Path: "org/repo.git", Username: "other"}
+	if c != want {
+		t.Fatalf("Read = %+v, want %+v", c, want)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Read(strings.NewReader("protocol\n")); err == nil {
+		t.Fatal("expected an error for a line without =")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, Credential{Username: "deploy", Password: "s3cr=t"}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got, want := buf.String(), "username=deploy\npassword=s3cr=t\n"; got != want {
+		t.Fatalf("Write = %q, want %q", got, want)
+	}
+
+	if err := Write(&buf, Credential{Password: "a\nb"}); err == nil {
+		t.Fatal("expected an error for a value containing a newline")
+	}
+}
@@ -5,7 +5,10 @@ go 1.24.0
 require (
 	github.com/99designs/keyring v1.2.2
 	github.com/dvcrn/go-1password-cli v0.0.0-20251007160526-078f32a60303
+	github.com/pelletier/go-toml/v2 v2.2.4
 	github.com/spf13/cobra v1.8.1
+	github.com/spf13/pflag v1.0.5
+	golang.org/x/term v0.3.0
 )
 
 require (
@@ -17,13 +20,10 @@ require (
 	github.com/gsterjov/go-libsecret v0.0.0-20161001094733-a6f4afe4910c // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mtibben/percent v0.2.1 // indirect
-	github.com/pelletier/go-toml/v2 v2.2.4 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/mod v0.28.0 // indirect
 	golang.org/x/sync v0.17.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
 	golang.org/x/telemetry v0.0.0-20250908211612-aef8a434d053 // indirect
-	golang.org/x/term v0.3.0 // indirect
 	golang.org/x/tools v0.37.0 // indirect
 	mvdan.cc/gofumpt v0.9.1 // indirect
 )
@@ -0,0 +1,186 @@
+// Package hygiene inspects stored secrets for common weaknesses without ever
+// exposing their values.
+package hygiene
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Checks reported in findings.
+const (
+	CheckWeak         = "weak"
+	CheckReused       = "reused"
+	CheckPlaceholder  = "placeholder"
+	CheckStale        = "stale"
+	CheckUnreferenced = "unreferenced"
+)
+
+// Defaults for Options.
+const (
+	DefaultMinLength  = 12
+	DefaultMinEntropy = 60
+)
+
+// Finding is a single problem with a stored secret. Detail never contains the
+// value itself.
+type Finding struct {
+	Key    string `json:"key"`
+	Check  string `json:"check"`
+	Detail string `json:"detail"`
+}
+
+// Options controls which checks run and their thresholds.
+type Options struct {
+	// MinLength and MinEntropy (in bits) below which a value is weak. Zero
+	// selects the defaults.
+	MinLength  int
+	MinEntropy float64
+
+	// StaleAfter is the age after which a key is stale. Stale keys are only
+	// reported if StaleAfter is positive and the key has a modification time
+	// in ModTimes.
+	StaleAfter time.Duration
+	ModTimes   map[string]time.Time
+	Now        time.Time
+
+	// Referenced holds the keys used by known configs. Unreferenced keys are
+	// only reported if Referenced is non-nil.
+	Referenced map[string]bool
+}
+
+// Analyze checks values, keyed by account, and returns findings sorted by key
+// and check.
+func Analyze(values map[string]string, opts Options) []Finding {
+	if opts.MinLength == 0 {
+		opts.MinLength = DefaultMinLength
+	}
+	if opts.MinEntropy == 0 {
+		opts.MinEntropy = DefaultMinEntropy
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	var findings []Finding
+	add := func(key, check, detail string) {
+		findings = append(findings, Finding{Key: key, Check: check, Detail: detail})
+	}
+
+	byValue := make(map[string][]string)
+	for key, value := range values {
+		if value != "" {
+			byValue[value] = append(byValue[value], key)
+		}
+	}
+
+	for key, value := range values {
+		switch {
+		case IsPlaceholder(value):
+			add(key, CheckPlaceholder, "value looks like a placeholder")
+		case len(value) < opts.MinLength:
+			add(key, CheckWeak, fmt.Sprintf("shorter than %d characters", opts.MinLength))
+		default:
+			if bits := Entropy(value); bits < opts.MinEntropy {
+				add(key, CheckWeak, fmt.Sprintf("estimated entropy of %.0f bits is below %.0f", bits, opts.MinEntropy))
+			}
+		}
+
+		if others := byValue[value]; len(others) > 1 {
+			shared := slices.DeleteFunc(slices.Clone(others), func(k string) bool { return k == key })
+			sort.Strings(shared)
+			add(key, CheckReused, "same value as "+strings.Join(shared, ", "))
+		}
+
+		if opts.StaleAfter > 0 {
+			if modified, ok := opts.ModTimes[key]; ok && opts.Now.Sub(modified) > opts.StaleAfter {
+				days := int(opts.Now.Sub(modified).Hours() / 24)
+				add(key, CheckStale, fmt.Sprintf("not modified in %d days", days))
+			}
+		}
+
+		if opts.Referenced != nil && !opts.Referenced[key] {
+			add(key, CheckUnreferenced, "not referenced by any known config")
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Key != findings[j].Key {
+			return findings[i].Key < findings[j].Key
+		}
+		return findings[i].Check < findings[j].Check
+	})
+	return findings
+}
+
+// Sizes of the character classes Entropy assumes a value was drawn from.
+const (
+	lowerSize  = 26
+	upperSize  = 26
+	digitSize  = 10
+	symbolSize = 33 // ASCII punctuation and space; also used for anything else
+)
+
+// Entropy estimates the entropy of value in bits as its length times log2 of
+// the combined size of the character classes it uses (lower case, upper case,
+// digits and symbols), the strength of a value chosen at random from them.
+func Entropy(value string) float64 {
+	var lower, upper, digit, symbol bool
+	n := 0
+	for _, r := range value {
+		n++
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += lowerSize
+	}
+	if upper {
+		size += upperSize
+	}
+	if digit {
+		size += digitSize
+	}
+	if symbol {
+		size += symbolSize
+	}
+	if size == 0 {
+		return 0
+	}
+	return float64(n) * math.Log2(float64(size))
+}
+
+var placeholderRegex = regexp.MustCompile(`(?i)^(` +
+	`changeme|change[-_ ]?me|todo|tbd|fixme|placeholder|example|sample|dummy|test|testing|secret|password|passw0rd|` +
+	`default|null|nil|none|empty|xxx+|\*+|\.\.\.|your[-_ ]?.*|replace[-_ ]?me|insert[-_ ]?.*|<.*>|\$\{.*\}|\{\{.*\}\}` +
+	`)$`)
+
+// IsPlaceholder reports whether value looks like a placeholder rather than a
+// real secret, such as "changeme", "<your-token>" or "xxxx".
+func IsPlaceholder(value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return true
+	}
+	if placeholderRegex.MatchString(value) {
+		return true
+	}
+	// A single repeated character, e.g. "0000000000".
+	first := []rune(value)[0]
+	return strings.Trim(value, string(first)) == ""
+}
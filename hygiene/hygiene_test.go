@@ -0,0 +1,115 @@
+package hygiene
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	values := map[string]string{
+		"STRONG":      "q8Zr2LmX0vTb7NcYw4Ke9HdJ",
+		"SHORT":       "abc123",
+		"LOWERCASE":   "correcthorse",
+		"PLACEHOLDER": "<your-api-key>",
+		"SHARED_A":    "Jk3nV8pQ2zL7xR4mT9wB6cYd",
+		"SHARED_B":    "Jk3nV8pQ2zL7xR4mT9wB6cYd",
+	}
+	findings := Analyze(values, Options{
+		Now:        now,
+		StaleAfter: 90 * 24 * time.Hour,
+		ModTimes: map[string]time.Time{
+			"STRONG":   now.Add(-10 * 24 * time.Hour),
+			"SHARED_A": now.Add(-200 * 24 * time.Hour),
+		},
+		Referenced: map[string]bool{"STRONG": true, "SHORT": true, "LOWERCASE": true, "PLACEHOLDER": true, "SHARED_A": true},
+	})
+
+	got := make(map[string]bool)
+	for _, f := range findings {
+		got[f.Key+":"+f.Check] = true
+	}
+	want := []string{
+		"SHORT:weak",
+		"LOWERCASE:weak",
+		"PLACEHOLDER:placeholder",
+		"SHARED_A:reused",
+		"SHARED_B:reused",
+		"SHARED_A:stale",
+		"SHARED_B:unreferenced",
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("missing finding %s in %v", w, findings)
+		}
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %d findings, want %d: %v", len(findings), len(want), findings)
+	}
+
+	for _, f := range findings {
+		for _, v := range values {
+			if strings.Contains(f.Detail, v) {
+				t.Fatalf("finding %v contains a secret value", f)
+			}
+		}
+	}
+}
+
+func TestAnalyzeSkipsOptionalChecks(t *testing.T) {
+	t.Parallel()
+
+	findings := Analyze(map[string]string{"KEY": "q8Zr2LmX0vTb7NcYw4Ke9HdJ"}, Options{})
+	if len(findings) != 0 {
+		t.Fatalf("unexpected findings without mod times or references: %v", findings)
+	}
+}
+
+func TestIsPlaceholder(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"changeme", "CHANGE_ME", "TODO", "xxxx", "********", "<token>", "${API_KEY}", "your-token-here", "0000000000", ""} {
+		if !IsPlaceholder(v) {
+			t.Errorf("IsPlaceholder(%q) = false, want true", v)
+		}
+	}
+	for _, v := range []string{"ghp_q8Zr2LmX0vTb7NcYw4Ke9HdJ", "correct-horse-battery-staple"} {
+		if IsPlaceholder(v) {
+			t.Errorf("IsPlaceholder(%q) = true, want false", v)
+		}
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]float64{
+		"":     0,
+		"abcd": 4 * math.Log2(26),
+		"aB3$": 4 * math.Log2(95),
+		"1234": 4 * math.Log2(10),
+	}
+	for value, want := range tests {
+		if got := Entropy(value); math.Abs(got-want) > 1e-9 {
+			t.Errorf("Entropy(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestRandomPasswordIsNotWeak(t *testing.T) {
+	t.Parallel()
+
+	// Generated at random from all 95 printable ASCII characters, so it has
+	// about 92 bits of entropy even though few characters repeat.
+	const password = "t4#Qm9!vZr2&Lx"
+	if got := Entropy(password); got < 90 {
+		t.Errorf("Entropy(%q) = %.1f, want about 92", password, got)
+	}
+	if findings := Analyze(map[string]string{"KEY": password}, Options{}); len(findings) != 0 {
+		t.Errorf("unexpected findings for a random password: %v", findings)
+	}
+}
@@ -0,0 +1,573 @@
+// Package fakeop emulates the subset of the 1Password CLI that
+// go-1password-cli uses, so OnePasswordBackend can be tested without an
+// account.
+//
+// Start builds the fake into a temporary directory and puts it first on
+// PATH. Every invocation reads and writes its vaults and items from a state
+// file in that directory, so separate op processes, including concurrent
+// ones, see each other's changes. Tests can seed vaults and items, script
+// failures and latency, and inspect the commands that were run.
+package fakeop
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvcrn/go-1password-cli/op"
+)
+
+// StateDirEnv tells the fake where its state lives.
+const StateDirEnv = "FAKEOP_STATE_DIR"
+
+const (
+	stateFile = "state.json"
+	lockFile  = "state.lock"
+
+	lockTimeout = 10 * time.Second
+)
+
+// Failure makes commands starting with Command, e.g. "item edit" or "read",
+// fail with Message on stderr. It applies Times times, or to every matching
+// command if Times is 0.
+type Failure struct {
+	Command string `json:"command"`
+	Message string `json:"message"`
+	Times   int    `json:"times,omitempty"`
+}
+
+// Vault is a vault and the description it was created with.
+type Vault struct {
+	op.Vault
+	Description string `json:"description,omitempty"`
+}
+
+// State is everything the fake knows. It is stored as JSON between
+// invocations.
+type State struct {
+	Vaults   []*Vault   `json:"vaults"`
+	Items    []*op.Item `json:"items"`
+	Failures []*Failure `json:"failures,omitempty"`
+	Latency  Duration   `json:"latency,omitempty"`
+	Calls    [][]string `json:"calls,omitempty"`
+	Seq      int        `json:"seq"`
+}
+
+// Duration is a time.Duration encoded as a string.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	*d = Duration(v)
+	return err
+}
+
+// Fake is a fake op executable on PATH.
+type Fake struct {
+	t   *testing.T
+	dir string
+}
+
+// Start builds the fake op into a temporary directory, puts it first on PATH
+// and points it at empty state. It uses t.Setenv, so the test can't be
+// parallel.
+func Start(t *testing.T) *Fake {
+	t.Helper()
+	dir := t.TempDir()
+	build := exec.Command("go", "build", "-o", filepath.Join(dir, "op"), "github.com/dvcrn/chainenv/internal/fakeop/op")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("build fake op: %v\n%s", err, out)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv(StateDirEnv, dir)
+
+	f := &Fake{t: t, dir: dir}
+	f.update(func(*State) {})
+	return f
+}
+
+// update applies fn to the stored state.
+func (f *Fake) update(fn func(*State)) {
+	f.t.Helper()
+	if err := withState(f.dir, func(s *State) error {
+		fn(s)
+		return nil
+	}); err != nil {
+		f.t.Fatalf("update fake op state: %v", err)
+	}
+}
+
+// State returns a snapshot of the stored state.
+func (f *Fake) State() *State {
+	f.t.Helper()
+	var state *State
+	f.update(func(s *State) { state = s })
+	return state
+}
+
+// AddVault creates a vault and returns its ID.
+func (f *Fake) AddVault(name string) string {
+	f.t.Helper()
+	var id string
+	f.update(func(s *State) { id = s.createVault(name, "").ID })
+	return id
+}
+
+// AddItem creates a password item in vault, given by name or ID.
+func (f *Fake) AddItem(vault, title, password string, tags ...string) {
+	f.t.Helper()
+	f.update(func(s *State) {
+		v := s.vault(vault)
+		if v == nil {
+			f.t.Fatalf("add item %s: no vault %s", title, vault)
+		}
+		s.createItem(v, "password", title, tags, map[string]string{"password": password})
+	})
+}
+
+// Fail scripts a failure, see Failure.
+func (f *Fake) Fail(command string, times int, message string) {
+	f.t.Helper()
+	f.update(func(s *State) {
+		s.Failures = append(s.Failures, &Failure{Command: command, Message: message, Times: times})
+	})
+}
+
+// SetLatency makes every command take at least d.
+func (f *Fake) SetLatency(d time.Duration) {
+	f.t.Helper()
+	f.update(func(s *State) { s.Latency = Duration(d) })
+}
+
+// Calls returns the arguments of every command run so far, without the
+// --format flag.
+func (f *Fake) Calls() [][]string {
+	f.t.Helper()
+	return f.State().Calls
+}
+
+// CountCalls returns how many commands started with command, e.g.
+// "vault create".
+func (f *Fake) CountCalls(command string) int {
+	f.t.Helper()
+	n := 0
+	for _, call := range f.Calls() {
+		if matches(call, command) {
+			n++
+		}
+	}
+	return n
+}
+
+// matches reports whether args start with the words of command.
+func matches(args []string, command string) bool {
+	words := strings.Fields(command)
+	return len(args) >= len(words) && slices.Equal(args[:len(words)], words)
+}
+
+// withState runs fn on the state stored in dir while holding its lock and
+// saves it afterwards.
+func withState(dir string, fn func(*State) error) error {
+	unlock, err := lock(filepath.Join(dir, lockFile))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	path := filepath.Join(dir, stateFile)
+	state := &State{}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, state); err != nil {
+			return fmt.Errorf("parse state: %w", err)
+		}
+	}
+
+	fnErr := fn(state)
+
+	data, err = json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// lock takes an exclusive lock by creating path, waiting for other holders
+// to remove it.
+func lock(path string) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", path)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Run executes the op command line args against the state in the directory
+// named by StateDirEnv and returns the exit code.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	dir := os.Getenv(StateDirEnv)
+	if dir == "" {
+		fmt.Fprintf(stderr, "[ERROR] %s is not set\n", StateDirEnv)
+		return 1
+	}
+
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "[ERROR] read stdin: %v\n", err)
+		return 1
+	}
+
+	cmd := parseArgs(args)
+	var latency time.Duration
+	var out []byte
+	err = withState(dir, func(s *State) error {
+		s.Calls = append(s.Calls, cmd.words())
+		latency = time.Duration(s.Latency)
+		if err := s.scriptedFailure(cmd); err != nil {
+			return err
+		}
+		out, err = s.run(cmd, input)
+		return err
+	})
+	// Sleep without holding the lock, so slow commands can overlap.
+	time.Sleep(latency)
+	if err != nil {
+		fmt.Fprintf(stderr, "[ERROR] %s %v\n", time.Now().Format("2006/01/02 15:04:05"), err)
+		return 1
+	}
+	stdout.Write(out)
+	return 0
+}
+
+// command is a parsed op command line.
+type command struct {
+	args  []string
+	flags map[string]string
+}
+
+// valueFlags are the flags that take a value.
+var valueFlags = []string{"--vault", "--category", "--title", "--tags", "--description", "--icon", "--format"}
+
+func parseArgs(args []string) command {
+	cmd := command{flags: make(map[string]string)}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if name, value, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(name, "--") {
+			cmd.flags[name] = value
+			continue
+		}
+		if slices.Contains(valueFlags, arg) && i+1 < len(args) {
+			cmd.flags[arg] = args[i+1]
+			i++
+			continue
+		}
+		cmd.args = append(cmd.args, arg)
+	}
+	return cmd
+}
+
+// words returns the command line without --format.
+func (c command) words() []string {
+	words := slices.Clone(c.args)
+	for _, name := range valueFlags {
+		if value, ok := c.flags[name]; ok && name != "--format" {
+			words = append(words, name, value)
+		}
+	}
+	return words
+}
+
+func (s *State) scriptedFailure(cmd command) error {
+	for i, f := range s.Failures {
+		if !matches(cmd.args, f.Command) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.Failures = slices.Delete(s.Failures, i, i+1)
+			}
+		}
+		return errors.New(f.Message)
+	}
+	return nil
+}
+
+func (s *State) run(cmd command, input []byte) ([]byte, error) {
+	args := cmd.args
+	switch {
+	case matches(args, "vault list"):
+		vaults := make([]op.Vault, 0, len(s.Vaults))
+		for _, v := range s.Vaults {
+			vaults = append(vaults, v.Vault)
+		}
+		return marshal(vaults)
+
+	case matches(args, "vault create") && len(args) == 3:
+		return marshal(s.createVault(args[2], cmd.flags["--description"]).Vault)
+
+	case matches(args, "item get") && len(args) == 3:
+		v, err := s.requireVault(cmd.flags["--vault"])
+		if err != nil {
+			return nil, err
+		}
+		item, err := s.requireItem(v, args[2])
+		if err != nil {
+			return nil, err
+		}
+		return marshal(item)
+
+	case matches(args, "item create"):
+		v, err := s.requireVault(cmd.flags["--vault"])
+		if err != nil {
+			return nil, err
+		}
+		var tags []string
+		if t := cmd.flags["--tags"]; t != "" {
+			tags = strings.Split(t, ",")
+		}
+		return marshal(s.createItem(v, cmd.flags["--category"], cmd.flags["--title"], tags, assignments(args[2:])))
+
+	case matches(args, "item edit") && len(args) >= 3:
+		item := s.itemByID(args[2])
+		if item == nil {
+			return nil, fmt.Errorf("%q isn't an item. Specify the item with its UUID, name, or domain.", args[2])
+		}
+		for name, value := range assignments(args[3:]) {
+			setField(item, name, value)
+		}
+		item.Version++
+		item.UpdatedAt = time.Now().UTC()
+		return marshal(item)
+
+	case matches(args, "item list"):
+		v, err := s.requireVault(cmd.flags["--vault"])
+		if err != nil {
+			return nil, err
+		}
+		var tags []string
+		if t := cmd.flags["--tags"]; t != "" {
+			tags = strings.Split(t, ",")
+		}
+		items := []op.Item{}
+		for _, item := range s.Items {
+			if item.Vault.ID != v.ID || (len(tags) > 0 && !slices.ContainsFunc(tags, func(tag string) bool { return slices.Contains(item.Tags, tag) })) {
+				continue
+			}
+			listed := *item
+			listed.Fields = nil
+			items = append(items, listed)
+		}
+		return marshal(items)
+
+	case matches(args, "item delete") && len(args) == 3:
+		v, err := s.requireVault(cmd.flags["--vault"])
+		if err != nil {
+			return nil, err
+		}
+		item, err := s.requireItem(v, args[2])
+		if err != nil {
+			return nil, err
+		}
+		s.Items = slices.DeleteFunc(s.Items, func(i *op.Item) bool { return i == item })
+		return nil, nil
+
+	case matches(args, "read") && len(args) == 2:
+		value, err := s.read(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return []byte(value + "\n"), nil
+
+	case matches(args, "inject"):
+		return s.inject(input)
+	}
+	return nil, fmt.Errorf("unknown command %q for the fake op", strings.Join(args, " "))
+}
+
+var referenceRegex = regexp.MustCompile(`\{\{\s*(op://[^}\s]+)\s*\}\}`)
+
+// inject replaces every {{ op://... }} reference in template. Like op, it
+// fails as a whole if a single reference can't be resolved.
+func (s *State) inject(template []byte) ([]byte, error) {
+	var err error
+	out := referenceRegex.ReplaceAllFunc(template, func(match []byte) []byte {
+		ref := string(referenceRegex.FindSubmatch(match)[1])
+		value, readErr := s.read(ref)
+		if readErr != nil && err == nil {
+			err = readErr
+		}
+		return []byte(value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// read resolves an op://vault/item/field reference.
+func (s *State) read(ref string) (string, error) {
+	path, ok := strings.CutPrefix(ref, "op://")
+	parts := strings.Split(path, "/")
+	if !ok || len(parts) != 3 {
+		return "", fmt.Errorf("invalid secret reference %q", ref)
+	}
+	v, err := s.requireVault(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("could not read secret '%s': %w", ref, err)
+	}
+	item, err := s.requireItem(v, parts[1])
+	if err != nil {
+		return "", fmt.Errorf("could not read secret '%s': error resolving item: %w", ref, err)
+	}
+	for _, field := range item.Fields {
+		if field.ID == parts[2] || field.Label == parts[2] {
+			return field.Value, nil
+		}
+	}
+	return "", fmt.Errorf("could not read secret '%s': item '%s' does not have a field '%s'", ref, item.Title, parts[2])
+}
+
+func (s *State) nextID() string {
+	s.Seq++
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return fmt.Sprintf("%x%04d", buf, s.Seq)
+}
+
+func (s *State) createVault(name, description string) *Vault {
+	v := &Vault{Vault: op.Vault{ID: s.nextID(), Name: name, ContentVersion: 1}, Description: description}
+	s.Vaults = append(s.Vaults, v)
+	return v
+}
+
+func (s *State) vault(idOrName string) *Vault {
+	for _, v := range s.Vaults {
+		if v.ID == idOrName || v.Name == idOrName {
+			return v
+		}
+	}
+	return nil
+}
+
+func (s *State) requireVault(idOrName string) (*Vault, error) {
+	if v := s.vault(idOrName); v != nil {
+		return v, nil
+	}
+	return nil, fmt.Errorf("%q isn't a vault in this account. Specify the vault with its ID or name.", idOrName)
+}
+
+func (s *State) createItem(v *Vault, category, title string, tags []string, fields map[string]string) *op.Item {
+	now := time.Now().UTC()
+	item := &op.Item{
+		ID:        s.nextID(),
+		Title:     title,
+		Category:  strings.ToUpper(category),
+		Tags:      tags,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	item.Vault.ID = v.ID
+	item.Vault.Name = v.Name
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		setField(item, name, fields[name])
+	}
+	s.Items = append(s.Items, item)
+	return item
+}
+
+func (s *State) itemByID(id string) *op.Item {
+	for _, item := range s.Items {
+		if item.ID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+func (s *State) requireItem(v *Vault, idOrTitle string) (*op.Item, error) {
+	for _, item := range s.Items {
+		if item.Vault.ID == v.ID && (item.ID == idOrTitle || item.Title == idOrTitle) {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("%q isn't an item in the %q vault. Specify the item with its UUID, name, or domain.", idOrTitle, v.Name)
+}
+
+func setField(item *op.Item, name, value string) {
+	for i := range item.Fields {
+		if item.Fields[i].ID == name || item.Fields[i].Label == name {
+			item.Fields[i].Value = value
+			return
+		}
+	}
+	field := op.Field{ID: name, Type: "STRING", Label: name, Value: value}
+	switch name {
+	case "password":
+		field.Type, field.Purpose = "CONCEALED", "PASSWORD"
+	case "notes":
+		field.Purpose = "NOTES"
+	}
+	field.Reference = op.ItemFieldRef(item.Vault.ID, item.ID, name)
+	item.Fields = append(item.Fields, field)
+}
+
+// assignments parses name=value field assignments.
+func assignments(args []string) map[string]string {
+	fields := make(map[string]string)
+	for _, arg := range args {
+		if name, value, ok := strings.Cut(arg, "="); ok {
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+func marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,12 @@
+// Command op is a fake 1Password CLI for tests, see package fakeop.
+package main
+
+import (
+	"os"
+
+	"github.com/dvcrn/chainenv/internal/fakeop"
+)
+
+func main() {
+	os.Exit(fakeop.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -1,36 +1,105 @@
+// Package logger provides the leveled diagnostics of chainenv. Records are
+// written as text or JSON through log/slog, and any secret value the logger
+// has been told about is scrubbed from them before they are written.
 package logger
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"strings"
 )
 
+// Output formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{FormatText, FormatJSON}
+
+// Options configure a Logger.
+type Options struct {
+	// Level is the minimum level that is logged.
+	Level slog.Level
+	// Format is FormatText (the default) or FormatJSON.
+	Format string
+}
+
 type Logger struct {
-	debug   *log.Logger
-	info    *log.Logger
-	err     *log.Logger
-	isDebug bool
+	slog    *slog.Logger
+	secrets *secretSet
 }
 
+// NewLogger returns a text logger writing to stderr, including debug
+// messages if debugEnabled is set.
 func NewLogger(debugEnabled bool) *Logger {
+	opts := Options{Level: slog.LevelInfo}
+	if debugEnabled {
+		opts.Level = slog.LevelDebug
+	}
+	return New(os.Stderr, opts)
+}
+
+// New returns a Logger writing records to w.
+func New(w io.Writer, opts Options) *Logger {
+	var h slog.Handler
+	if opts.Format == FormatJSON {
+		h = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: opts.Level})
+	} else {
+		h = newTextHandler(w, opts.Level)
+	}
+	secrets := &secretSet{}
 	return &Logger{
-		debug:   log.New(os.Stdout, "DEBUG: ", 0),
-		info:    log.New(os.Stdout, "INFO: ", 0),
-		err:     log.New(os.Stderr, "ERR: ", 0),
-		isDebug: debugEnabled,
+		slog:    slog.New(&redactHandler{next: h, secrets: secrets}),
+		secrets: secrets,
 	}
 }
 
-func (l *Logger) Debug(format string, v ...interface{}) {
-	if l.isDebug {
-		l.debug.Printf(format, v...)
+// ParseLevel parses a level name: debug, info, warn or error.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", name)
 	}
+	return level, nil
+}
+
+// AddSecrets makes the logger scrub values, and their common encodings, from
+// everything it logs from now on.
+func (l *Logger) AddSecrets(values ...string) {
+	l.secrets.add(values)
+}
+
+// Slog returns the underlying slog.Logger, for logging structured
+// attributes. Its records are redacted as well.
+func (l *Logger) Slog() *slog.Logger {
+	return l.slog
+}
+
+func (l *Logger) Debug(format string, v ...interface{}) {
+	l.log(slog.LevelDebug, format, v...)
 }
 
 func (l *Logger) Info(format string, v ...interface{}) {
-	l.info.Printf(format, v...)
+	l.log(slog.LevelInfo, format, v...)
+}
+
+func (l *Logger) Warn(format string, v ...interface{}) {
+	l.log(slog.LevelWarn, format, v...)
 }
 
 func (l *Logger) Err(format string, v ...interface{}) {
-	l.err.Printf(format, v...)
+	l.log(slog.LevelError, format, v...)
+}
+
+func (l *Logger) log(level slog.Level, format string, v ...interface{}) {
+	ctx := context.Background()
+	if !l.slog.Enabled(ctx, level) {
+		return
+	}
+	l.slog.Log(ctx, level, strings.TrimRight(fmt.Sprintf(format, v...), "\n"))
 }
@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTextFormat(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := New(&buf, Options{Level: slog.LevelDebug})
+	l.Debug("Getting %s\n", "API_TOKEN")
+	l.Info("Created vault")
+	l.Warn("Careful")
+	l.Err("Failed: %v", errors.New("boom"))
+	l.Slog().With("provider", "1password").WithGroup("item").Info("Read", "key", "A B", "n", 2)
+
+	want := `DEBUG: Getting API_TOKEN
+INFO: Created vault
+WARN: Careful
+ERR: Failed: boom
+INFO: Read provider=1password item.key="A B" item.n=2
+`
+	if buf.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := New(&buf, Options{Level: slog.LevelWarn})
+	l.Debug("debug")
+	l.Info("info")
+	l.Warn("warn")
+	l.Err("err")
+	if want := "WARN: warn\nERR: err\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := New(&buf, Options{Level: slog.LevelInfo, Format: FormatJSON})
+	l.Debug("hidden")
+	l.Info("Created vault: %s", "chainenv")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("output %q isn't a single JSON record: %v", buf.String(), err)
+	}
+	if record["level"] != "INFO" || record["msg"] != "Created vault: chainenv" || record["time"] == nil {
+		t.Errorf("record = %v", record)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+	for name, want := range tests {
+		if got, err := ParseLevel(name); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("ParseLevel(loud) succeeded")
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		l := New(&buf, Options{Level: slog.LevelDebug, Format: format})
+		l.Info("before: hunter22")
+		l.AddSecrets("hunter22", "abc", "")
+
+		l.Debug("value: %s", "hunter22")
+		l.Debug("encoded: %s", base64.StdEncoding.EncodeToString([]byte("hunter22")))
+		l.Slog().With("token", "hunter22").Info("attrs",
+			"err", errors.New("bad password hunter22"),
+			slog.Group("item", "value", "xhunter22x"))
+		l.Info("short values like abc are kept")
+
+		out := buf.String()
+		if !strings.Contains(out, "before: hunter22") {
+			t.Errorf("%s: records logged before AddSecrets were changed: %s", format, out)
+		}
+		if n := strings.Count(out, "hunter22"); n != 1 {
+			t.Errorf("%s: secret appears %d times, want only in the first record:\n%s", format, n, out)
+		}
+		if n := strings.Count(out, "***"); n != 5 {
+			t.Errorf("%s: found %d masks, want 5:\n%s", format, n, out)
+		}
+		if !strings.Contains(out, "short values like abc are kept") {
+			t.Errorf("%s: values shorter than redact.MinLength were masked:\n%s", format, out)
+		}
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := New(&buf, Options{Level: slog.LevelInfo})
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.AddSecrets(strings.Repeat("s", 4+i))
+			l.Info("message %d", i)
+		}()
+	}
+	wg.Wait()
+	if n := strings.Count(buf.String(), "\n"); n != 10 {
+		t.Errorf("got %d lines, want 10:\n%s", n, buf.String())
+	}
+}
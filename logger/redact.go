@@ -0,0 +1,115 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/dvcrn/chainenv/redact"
+)
+
+// secretSet holds the values a logger scrubs from its records.
+type secretSet struct {
+	mu       sync.RWMutex
+	values   []string
+	seen     map[string]bool
+	patterns [][]byte
+}
+
+func (s *secretSet) add(values []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+	added := false
+	for _, v := range values {
+		if len(v) < redact.MinLength || s.seen[v] {
+			continue
+		}
+		s.seen[v] = true
+		s.values = append(s.values, v)
+		added = true
+	}
+	if added {
+		s.patterns = redact.Patterns(s.values)
+	}
+}
+
+// redact replaces every known secret in text with redact.Mask.
+func (s *secretSet) redact(text string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.patterns) == 0 {
+		return text
+	}
+	b := []byte(text)
+	changed := false
+	for _, p := range s.patterns {
+		if bytes.Contains(b, p) {
+			b = bytes.ReplaceAll(b, p, []byte(redact.Mask))
+			changed = true
+		}
+	}
+	if !changed {
+		return text
+	}
+	return string(b)
+}
+
+// redactHandler scrubs known secrets from the message and attributes of
+// records before passing them on.
+type redactHandler struct {
+	next    slog.Handler
+	secrets *secretSet
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, h.secrets.redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactAttr(a)
+	}
+	return &redactHandler{next: h.next.WithAttrs(redacted), secrets: h.secrets}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), secrets: h.secrets}
+}
+
+func (h *redactHandler) redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(h.secrets.redact(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = h.redactAttr(ga)
+		}
+		a.Value = slog.GroupValue(redacted...)
+	case slog.KindAny:
+		// Errors and other values are logged through their string form,
+		// which may contain a secret.
+		s := fmt.Sprint(a.Value.Any())
+		if r := h.secrets.redact(s); r != s {
+			a.Value = slog.StringValue(r)
+		}
+	}
+	return a
+}
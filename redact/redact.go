@@ -0,0 +1,158 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Mask replaces every redacted value.
+const Mask = "***"
+
+// MinLength is the shortest value that is redacted. Shorter values would mask
+// too much unrelated output to be useful.
+const MinLength = 4
+
+// Encoding is a form in which a secret value may appear in output.
+type Encoding struct {
+	Name   string
+	Encode func(string) string
+}
+
+// Encodings lists the forms of a value that are redacted, the value itself
+// first.
+var Encodings = []Encoding{
+	{"raw", func(s string) string { return s }},
+	{"base64", func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }},
+	{"base64", func(s string) string { return base64.RawStdEncoding.EncodeToString([]byte(s)) }},
+	{"base64url", func(s string) string { return base64.URLEncoding.EncodeToString([]byte(s)) }},
+	{"base64url", func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }},
+	{"url", url.QueryEscape},
+	{"url", url.PathEscape},
+}
+
+// Patterns returns the byte sequences to redact for values: each value in
+// every one of Encodings. Values shorter than MinLength are ignored.
+func Patterns(values []string) [][]byte {
+	seen := make(map[string]bool)
+	var patterns [][]byte
+	for _, v := range values {
+		if len(v) < MinLength {
+			continue
+		}
+		for _, enc := range Encodings {
+			s := enc.Encode(v)
+			if len(s) < MinLength || seen[s] {
+				continue
+			}
+			seen[s] = true
+			patterns = append(patterns, []byte(s))
+		}
+	}
+
+	// Prefer the longest match when patterns overlap.
+	sort.Slice(patterns, func(i, j int) bool { return len(patterns[i]) > len(patterns[j]) })
+	return patterns
+}
+
+// Writer replaces occurrences of patterns in everything written to it before
+// passing it on. Matches may span multiple writes: bytes that could be the
+// start of a pattern are held back until the next write or Flush.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	byFirst map[byte][][]byte
+	pending []byte
+}
+
+// NewWriter returns a Writer redacting patterns from output written to w.
+func NewWriter(w io.Writer, patterns [][]byte) *Writer {
+	byFirst := make(map[byte][][]byte)
+	for _, p := range patterns {
+		if len(p) == 0 {
+			continue
+		}
+		byFirst[p[0]] = append(byFirst[p[0]], p)
+	}
+	return &Writer{w: w, byFirst: byFirst}
+}
+
+// Write redacts p and writes all output that can no longer be part of a match.
+func (r *Writer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buf := append(r.pending, p...)
+	out, rest := r.redact(buf, false)
+	r.pending = append([]byte(nil), rest...)
+
+	if len(out) > 0 {
+		if _, err := r.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes any held back output. It must be called once no more data
+// will be written.
+func (r *Writer) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.pending) == 0 {
+		return nil
+	}
+	out, _ := r.redact(r.pending, true)
+	r.pending = nil
+	_, err := r.w.Write(out)
+	return err
+}
+
+// redact returns the redacted output for buf and the trailing bytes that are
+// a proper prefix of a pattern and therefore have to wait for more input.
+// If final is set, no more input will follow and nothing is held back.
+func (r *Writer) redact(buf []byte, final bool) (out, rest []byte) {
+	var b bytes.Buffer
+	start := 0
+	for i := 0; i < len(buf); {
+		candidates := r.byFirst[buf[i]]
+		if len(candidates) == 0 {
+			i++
+			continue
+		}
+
+		matched := 0
+		partial := false
+		// Candidates are sorted longest first, so a longer pattern that
+		// might still match takes precedence over a shorter complete one.
+		for _, p := range candidates {
+			if bytes.HasPrefix(buf[i:], p) {
+				matched = len(p)
+				break
+			}
+			if !final && len(buf)-i < len(p) && bytes.HasPrefix(p, buf[i:]) {
+				partial = true
+				break
+			}
+		}
+
+		switch {
+		case matched > 0:
+			b.Write(buf[start:i])
+			b.WriteString(Mask)
+			i += matched
+			start = i
+		case partial:
+			b.Write(buf[start:i])
+			return b.Bytes(), buf[i:]
+		default:
+			i++
+		}
+	}
+	b.Write(buf[start:])
+	return b.Bytes(), nil
+}
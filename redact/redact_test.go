@@ -0,0 +1,80 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"testing"
+)
+
+func TestWriterRedactsValues(t *testing.T) {
+	t.Parallel()
+
+	secret := "s3cr3t/t0ken+"
+	var out bytes.Buffer
+	w := NewWriter(&out, Patterns([]string{secret, "abc"}))
+
+	input := "token=" + secret + " b64=" + base64.StdEncoding.EncodeToString([]byte(secret)) +
+		" url=" + url.QueryEscape(secret) + " short=abc\n"
+	if _, err := w.Write([]byte(input)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	if want := "token=*** b64=*** url=*** short=abc\n"; out.String() != want {
+		t.Fatalf("expected %q, got %q", want, out.String())
+	}
+}
+
+func TestWriterSplitAcrossWrites(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	w := NewWriter(&out, Patterns([]string{"password123"}))
+
+	for _, chunk := range []string{"before pass", "wor", "d123 after pa", "ss"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if got := out.String(); got != "before *** after " {
+		t.Fatalf("unexpected output before flush: %q", got)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if want := "before *** after pass"; out.String() != want {
+		t.Fatalf("expected %q, got %q", want, out.String())
+	}
+}
+
+func TestWriterDoesNotHoldBackUnrelatedOutput(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	w := NewWriter(&out, Patterns([]string{"password123"}))
+
+	if _, err := w.Write([]byte("progress 10%\r")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if want := "progress 10%\r"; out.String() != want {
+		t.Fatalf("expected %q to be written immediately, got %q", want, out.String())
+	}
+}
+
+func TestPatternsPrefersLongest(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	w := NewWriter(&out, Patterns([]string{"secret", "secret-extended"}))
+	w.Write([]byte("secret-ext"))
+	w.Write([]byte("ended secret"))
+	w.Flush()
+
+	if want := Mask + " " + Mask; out.String() != want {
+		t.Fatalf("expected %q, got %q", want, out.String())
+	}
+}
@@ -0,0 +1,83 @@
+package ref
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Scheme is the prefix of secret references.
+const Scheme = "chainenv://"
+
+// pattern matches chainenv://[provider/]KEY. Provider names are lowercase
+// alphanumerics; keys may additionally contain dots and dashes.
+var pattern = regexp.MustCompile(`chainenv://(?:([a-z0-9]+)/)?([A-Za-z0-9_][A-Za-z0-9_.\-]*)`)
+
+// Reference points at a secret, optionally through an explicit provider.
+type Reference struct {
+	Provider string
+	Key      string
+}
+
+func (r Reference) String() string {
+	if r.Provider == "" {
+		return Scheme + r.Key
+	}
+	return Scheme + r.Provider + "/" + r.Key
+}
+
+// ResolveFunc returns the value a reference points at.
+type ResolveFunc func(Reference) (string, error)
+
+// Parse parses s if the whole string is a reference.
+func Parse(s string) (Reference, bool) {
+	m := pattern.FindStringSubmatchIndex(s)
+	if m == nil || m[0] != 0 || m[1] != len(s) {
+		return Reference{}, false
+	}
+	return fromMatch(s, m), true
+}
+
+// Contains reports whether s contains at least one reference.
+func Contains(s string) bool {
+	return strings.Contains(s, Scheme) && pattern.MatchString(s)
+}
+
+// Replace substitutes every reference in s with its resolved value. Each
+// distinct reference is resolved once. Resolution stops at the first error.
+func Replace(s string, resolve ResolveFunc) (string, error) {
+	matches := pattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	resolved := make(map[Reference]string)
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		r := fromMatch(s, m)
+		value, ok := resolved[r]
+		if !ok {
+			var err error
+			value, err = resolve(r)
+			if err != nil {
+				return "", err
+			}
+			resolved[r] = value
+		}
+
+		sb.WriteString(s[last:m[0]])
+		sb.WriteString(value)
+		last = m[1]
+	}
+	sb.WriteString(s[last:])
+	return sb.String(), nil
+}
+
+func fromMatch(s string, m []int) Reference {
+	var r Reference
+	if m[2] >= 0 {
+		r.Provider = s[m[2]:m[3]]
+	}
+	r.Key = s[m[4]:m[5]]
+	return r
+}
@@ -0,0 +1,59 @@
+package ref
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want Reference
+		ok   bool
+	}{
+		{"chainenv://DB_URL", Reference{Key: "DB_URL"}, true},
+		{"chainenv://keychain/db-url", Reference{Provider: "keychain", Key: "db-url"}, true},
+		{"chainenv://1password/API_KEY.previous", Reference{Provider: "1password", Key: "API_KEY.previous"}, true},
+		{"postgres://chainenv://DB_URL", Reference{}, false},
+		{"chainenv://", Reference{}, false},
+		{"DB_URL", Reference{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := Parse(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("Parse(%q) = %#v, %v; want %#v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	resolve := func(r Reference) (string, error) {
+		calls++
+		if r.Key == "MISSING" {
+			return "", fmt.Errorf("%s not found", r)
+		}
+		return r.Provider + ":" + r.Key, nil
+	}
+
+	in := "user=chainenv://USER pass=chainenv://keychain/PASS again=chainenv://USER\n"
+	got, err := Replace(in, resolve)
+	if err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if want := "user=:USER pass=keychain:PASS again=:USER\n"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 resolutions, got %d", calls)
+	}
+
+	if _, err := Replace("x=chainenv://MISSING", resolve); err == nil {
+		t.Fatalf("expected error for missing reference")
+	}
+}
@@ -0,0 +1,117 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/template"
+	"text/template/parse"
+)
+
+// LookupFunc resolves a secret by name.
+type LookupFunc func(name string) (string, error)
+
+// Template is a text/template with a `secret "NAME"` function.
+type Template struct {
+	tmpl *template.Template
+}
+
+// Parse parses text as a template. lookup is called for every `secret` call
+// during Execute and may be nil if the template is only inspected.
+func Parse(name, text string, lookup LookupFunc) (*Template, error) {
+	funcs := template.FuncMap{
+		"secret": func(key string) (string, error) {
+			if lookup == nil {
+				return "", fmt.Errorf("secret %q: no lookup configured", key)
+			}
+			return lookup(key)
+		},
+	}
+
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Execute renders the template.
+func (t *Template) Execute() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// References returns the sorted, deduplicated names passed as string literals
+// to `secret` anywhere in the template, including branches that would not be
+// executed. Calls with computed arguments are reported in dynamic.
+func (t *Template) References() (names []string, dynamic int) {
+	seen := make(map[string]bool)
+	for _, tmpl := range t.tmpl.Templates() {
+		if tmpl.Tree == nil {
+			continue
+		}
+		walk(tmpl.Tree.Root, func(cmd *parse.CommandNode) {
+			if len(cmd.Args) == 0 {
+				return
+			}
+			ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+			if !ok || ident.Ident != "secret" {
+				return
+			}
+			if len(cmd.Args) == 2 {
+				if s, ok := cmd.Args[1].(*parse.StringNode); ok {
+					seen[s.Text] = true
+					return
+				}
+			}
+			dynamic++
+		})
+	}
+
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, dynamic
+}
+
+func walk(node parse.Node, fn func(*parse.CommandNode)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walk(child, fn)
+		}
+	case *parse.ActionNode:
+		walk(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walk(cmd, fn)
+		}
+	case *parse.CommandNode:
+		fn(n)
+		for _, arg := range n.Args {
+			walk(arg, fn)
+		}
+	case *parse.IfNode:
+		walk(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walk(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walk(&n.BranchNode, fn)
+	case *parse.BranchNode:
+		walk(n.Pipe, fn)
+		walk(n.List, fn)
+		walk(n.ElseList, fn)
+	case *parse.TemplateNode:
+		walk(n.Pipe, fn)
+	}
+}
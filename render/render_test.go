@@ -0,0 +1,63 @@
+package render
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExecute(t *testing.T) {
+	t.Parallel()
+
+	secrets := map[string]string{"NPM_TOKEN": "abc123"}
+	lookup := func(name string) (string, error) {
+		if v, ok := secrets[name]; ok {
+			return v, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	tmpl, err := Parse("npmrc", `//registry.npmjs.org/:_authToken={{ secret "NPM_TOKEN" }}`, lookup)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out, err := tmpl.Execute()
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if want := "//registry.npmjs.org/:_authToken=abc123"; string(out) != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+
+	tmpl, err = Parse("missing", `{{ secret "MISSING" }}`, lookup)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := tmpl.Execute(); err == nil {
+		t.Fatalf("expected error for missing secret")
+	}
+}
+
+func TestReferences(t *testing.T) {
+	t.Parallel()
+
+	text := `{{ define "creds" }}{{ secret "IN_DEFINE" }}{{ end }}
+{{ if false }}{{ secret "IN_IF" }}{{ else }}{{ secret "IN_ELSE" | printf "%s" }}{{ end }}
+{{ with $x := "dyn" }}{{ secret $x }}{{ end }}
+{{ range $v := . }}{{ secret "IN_RANGE" }}{{ end }}
+{{ secret "IN_IF" }}`
+
+	tmpl, err := Parse("refs", text, nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	names, dynamic := tmpl.References()
+	want := []string{"IN_DEFINE", "IN_ELSE", "IN_IF", "IN_RANGE"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+	if dynamic != 1 {
+		t.Fatalf("expected 1 dynamic reference, got %d", dynamic)
+	}
+}
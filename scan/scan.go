@@ -0,0 +1,256 @@
+// Package scan finds secret values, raw or encoded, in files and git diffs.
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dvcrn/chainenv/redact"
+)
+
+// MinLength is the shortest value that is scanned for. Shorter values match
+// too much unrelated text to be useful.
+const MinLength = 8
+
+// binarySniffLen is how much of a file is checked for NUL bytes to decide
+// whether it is binary.
+const binarySniffLen = 8000
+
+// Finding is an occurrence of a secret. It identifies the secret by key and
+// never holds its value.
+type Finding struct {
+	Commit   string `json:"commit,omitempty"`
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	Key      string `json:"key"`
+	Encoding string `json:"encoding"`
+}
+
+func (f Finding) String() string {
+	loc := f.Path + ":" + strconv.Itoa(f.Line)
+	if f.Commit != "" {
+		loc = f.Commit + ":" + loc
+	}
+	if f.Encoding != "raw" {
+		return loc + ": " + f.Key + " (" + f.Encoding + ")"
+	}
+	return loc + ": " + f.Key
+}
+
+type needle struct {
+	key      string
+	encoding string
+	text     []byte
+}
+
+// Scanner searches text for a set of secrets.
+type Scanner struct {
+	needles []needle
+}
+
+// New returns a Scanner for secrets, keyed by name. Values shorter than
+// MinLength are ignored.
+func New(secrets map[string]string) *Scanner {
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	s := &Scanner{}
+	for _, key := range keys {
+		value := secrets[key]
+		if len(value) < MinLength {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, enc := range redact.Encodings {
+			text := enc.Encode(value)
+			if seen[text] {
+				continue
+			}
+			seen[text] = true
+			s.needles = append(s.needles, needle{key: key, encoding: enc.Name, text: []byte(text)})
+		}
+	}
+	return s
+}
+
+// Empty reports whether there is nothing to scan for.
+func (s *Scanner) Empty() bool {
+	return len(s.needles) == 0
+}
+
+// match returns a finding for every secret in line, reporting each key once.
+func (s *Scanner) match(line []byte, path string, lineNo int, commit string) []Finding {
+	var findings []Finding
+	var last string
+	for _, n := range s.needles {
+		if n.key == last {
+			continue
+		}
+		if bytes.Contains(line, n.text) {
+			findings = append(findings, Finding{Commit: commit, Path: path, Line: lineNo, Key: n.key, Encoding: n.encoding})
+			last = n.key
+		}
+	}
+	return findings
+}
+
+// Reader scans r line by line, reporting findings against path.
+func (s *Scanner) Reader(path string, r io.Reader) ([]Finding, error) {
+	var findings []Finding
+	br := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			findings = append(findings, s.match(line, path, lineNo, "")...)
+		}
+		if errors.Is(err, io.EOF) {
+			return findings, nil
+		}
+		if err != nil {
+			return findings, err
+		}
+	}
+}
+
+// File scans the file at path. Binary files are skipped.
+func (s *Scanner) File(path string) ([]Finding, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	head, _ := br.Peek(binarySniffLen)
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+	return s.Reader(path, br)
+}
+
+// Tree scans root, which may be a file or a directory. Directories are walked
+// recursively, skipping .git.
+func (s *Scanner) Tree(root string) ([]Finding, error) {
+	var findings []Finding
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		found, err := s.File(path)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, found...)
+		return nil
+	})
+	return findings, err
+}
+
+// Diff scans the lines added in a unified diff as produced by "git diff" or
+// "git log -p", reporting their path and line number in the new version and,
+// for logs, the commit that added them. The diff must use the default a/ and
+// b/ prefixes.
+func (s *Scanner) Diff(r io.Reader) ([]Finding, error) {
+	var (
+		findings     []Finding
+		commit, path string
+		newLine      int
+		oldLeft      int
+		newLeft      int
+	)
+
+	br := bufio.NewReader(r)
+	for {
+		raw, err := br.ReadBytes('\n')
+		if len(raw) > 0 {
+			line := bytes.TrimSuffix(raw, []byte("\n"))
+			switch {
+			case oldLeft > 0 || newLeft > 0:
+				// Inside a hunk, every line is content.
+				switch {
+				case len(line) > 0 && line[0] == '+':
+					findings = append(findings, s.match(line[1:], path, newLine, commit)...)
+					newLine++
+					newLeft--
+				case len(line) > 0 && line[0] == '-':
+					oldLeft--
+				case len(line) > 0 && line[0] == '\\':
+					// "\ No newline at end of file"
+				default:
+					newLine++
+					oldLeft--
+					newLeft--
+				}
+			case bytes.HasPrefix(line, []byte("commit ")):
+				commit = string(bytes.Fields(line)[1])
+			case bytes.HasPrefix(line, []byte("diff --git ")):
+				path = ""
+			case bytes.HasPrefix(line, []byte("+++ ")):
+				path = diffPath(string(line[4:]))
+			case bytes.HasPrefix(line, []byte("@@ ")):
+				oldLeft, newLine, newLeft = parseHunkHeader(string(line))
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return findings, nil
+		}
+		if err != nil {
+			return findings, err
+		}
+	}
+}
+
+// diffPath returns the path from a "+++" header, or "" for /dev/null.
+func diffPath(name string) string {
+	if strings.HasPrefix(name, `"`) {
+		if unquoted, err := strconv.Unquote(name); err == nil {
+			name = unquoted
+		}
+	}
+	if name == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(name, "b/")
+}
+
+// parseHunkHeader parses "@@ -l,s +l,s @@" and returns the number of old
+// lines, and the first line and number of new lines in the hunk.
+func parseHunkHeader(line string) (oldCount, newStart, newCount int) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return 0, 0, 0
+	}
+	_, oldCount = parseRange(strings.TrimPrefix(fields[1], "-"))
+	newStart, newCount = parseRange(strings.TrimPrefix(fields[2], "+"))
+	return oldCount, newStart, newCount
+}
+
+func parseRange(r string) (start, count int) {
+	startText, countText, hasCount := strings.Cut(r, ",")
+	start, _ = strconv.Atoi(startText)
+	count = 1
+	if hasCount {
+		count, _ = strconv.Atoi(countText)
+	}
+	return start, count
+}
@@ -0,0 +1,112 @@
+package scan
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const token = "ghp_q8Zr2LmX0vTb7NcYw4Ke9HdJ"
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	s := New(map[string]string{"GITHUB_TOKEN": token, "SHORT": "abc"})
+	input := "first line\n" +
+		"token = \"" + token + "\"\n" +
+		"encoded: " + base64.StdEncoding.EncodeToString([]byte(token)) + "\n" +
+		"abc is too short to match"
+
+	findings, err := s.Reader("config.yml", strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Reader: %v", err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("got %d findings, want 2: %v", len(findings), findings)
+	}
+	if f := findings[0]; f.Line != 2 || f.Key != "GITHUB_TOKEN" || f.Encoding != "raw" {
+		t.Fatalf("unexpected first finding: %+v", f)
+	}
+	if f := findings[1]; f.Line != 3 || f.Encoding != "base64" {
+		t.Fatalf("unexpected second finding: %+v", f)
+	}
+	for _, f := range findings {
+		if strings.Contains(f.String(), token) {
+			t.Fatalf("finding %q contains the secret", f.String())
+		}
+	}
+}
+
+func TestTreeSkipsGitAndBinaryFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("src/main.go", "package main\n\nconst key = \""+token+"\"\n")
+	write(".git/config", token)
+	write("bin/tool", "\x00\x01"+token)
+
+	findings, err := New(map[string]string{"GITHUB_TOKEN": token}).Tree(dir)
+	if err != nil {
+		t.Fatalf("Tree: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Path != filepath.Join(dir, "src/main.go") || findings[0].Line != 3 {
+		t.Fatalf("unexpected findings: %v", findings)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	diff := `commit 1234567890abcdef
+Author: Someone <someone@example.com>
+
+    Add config
+
+diff --git a/.env b/.env
+new file mode 100644
+index 0000000..e69de29
+--- /dev/null
++++ b/.env
+@@ -0,0 +1,2 @@
++NAME=app
++TOKEN=` + token + `
+diff --git a/app.go b/app.go
+index 1111111..2222222 100644
+--- a/app.go
++++ b/app.go
+@@ -10,2 +10,2 @@ func main() {
+-	old := "` + token + `"
++	tok := os.Getenv("TOKEN")
+ 	run(tok)
+@@ -20 +20,2 @@
++++ b/not-a-header ` + token + `
+ unchanged
+`
+	findings, err := New(map[string]string{"GITHUB_TOKEN": token}).Diff(strings.NewReader(diff))
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	want := []Finding{
+		{Commit: "1234567890abcdef", Path: ".env", Line: 2, Key: "GITHUB_TOKEN", Encoding: "raw"},
+		{Commit: "1234567890abcdef", Path: "app.go", Line: 20, Key: "GITHUB_TOKEN", Encoding: "raw"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %v, want %v", findings, want)
+	}
+	for i := range want {
+		if findings[i] != want[i] {
+			t.Fatalf("finding %d = %+v, want %+v", i, findings[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package secretfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dvcrn/chainenv/fsutil"
+)
+
+// DirEnv is the environment variable pointing at the directory of the
+// current session, so that it can be cleaned up later.
+const DirEnv = "CHAINENV_FILES_DIR"
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9_.\-]`)
+
+// BaseDir returns the private directory sessions are created in. It prefers
+// $XDG_RUNTIME_DIR, which is a per-user tmpfs on most Linux systems.
+func BaseDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "chainenv")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("chainenv-%d", os.Getuid()))
+}
+
+// Session is a private directory holding secret files.
+type Session struct {
+	Dir string
+}
+
+// NewSession creates a new session directory below BaseDir.
+func NewSession() (*Session, error) {
+	base := BaseDir()
+	if err := fsutil.MkdirPrivate(base); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(base, "session-")
+	if err != nil {
+		return nil, fmt.Errorf("create session dir: %w", err)
+	}
+	return &Session{Dir: dir}, nil
+}
+
+// Write stores value in a 0600 file named after name and returns its path.
+func (s *Session) Write(name, value string) (string, error) {
+	path := filepath.Join(s.Dir, unsafeChars.ReplaceAllString(name, "_"))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("create secret file: %w", err)
+	}
+	if _, err := f.WriteString(value); err != nil {
+		f.Close()
+		return "", fmt.Errorf("write secret file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("write secret file: %w", err)
+	}
+	return path, nil
+}
+
+// Remove deletes the session directory and all files in it.
+func (s *Session) Remove() error {
+	return os.RemoveAll(s.Dir)
+}
+
+// RemoveDir deletes a session directory created by NewSession. It refuses to
+// remove directories outside BaseDir.
+func RemoveDir(dir string) error {
+	base, err := filepath.Abs(BaseDir())
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if filepath.Dir(abs) != base || !strings.HasPrefix(filepath.Base(abs), "session-") {
+		return fmt.Errorf("%s is not a chainenv session directory", dir)
+	}
+	return os.RemoveAll(abs)
+}
+
+// RemoveAll deletes every session directory.
+func RemoveAll() error {
+	return os.RemoveAll(BaseDir())
+}
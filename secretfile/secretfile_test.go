@@ -0,0 +1,54 @@
+package secretfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	s, err := NewSession()
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+
+	path, err := s.Write("GOOGLE/CREDS", `{"type":"service_account"}`)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if filepath.Dir(path) != s.Dir || filepath.Base(path) != "GOOGLE_CREDS" {
+		t.Fatalf("unexpected path %s", path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("expected 0600, got %v", perm)
+	}
+	if info, err := os.Stat(BaseDir()); err != nil || info.Mode().Perm() != 0o700 {
+		t.Fatalf("expected private base dir, got %v, %v", info, err)
+	}
+
+	if err := RemoveDir(s.Dir); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := os.Stat(s.Dir); !os.IsNotExist(err) {
+		t.Fatalf("expected session dir to be removed, got %v", err)
+	}
+}
+
+func TestRemoveDirOutsideBase(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	other := t.TempDir()
+	if err := RemoveDir(other); err == nil {
+		t.Fatalf("expected error removing %s", other)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Fatalf("expected %s to still exist: %v", other, err)
+	}
+}
@@ -0,0 +1,179 @@
+package secretgen
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	ModeChars      = "chars"
+	ModePassphrase = "passphrase"
+	ModeHex        = "hex"
+	ModeBase64     = "base64"
+)
+
+const (
+	CharsetAlphanumeric = "alphanumeric"
+	CharsetAlpha        = "alpha"
+	CharsetLower        = "lower"
+	CharsetLowerAlnum   = "lower-alnum"
+	CharsetNumeric      = "numeric"
+	CharsetSymbols      = "symbols"
+)
+
+const (
+	DefaultLength    = 32
+	DefaultWords     = 8
+	DefaultSeparator = "-"
+)
+
+var charsets = map[string]string{
+	CharsetAlphanumeric: "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+	CharsetAlpha:        "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+	CharsetLower:        "abcdefghijklmnopqrstuvwxyz",
+	CharsetLowerAlnum:   "abcdefghijklmnopqrstuvwxyz0123456789",
+	CharsetNumeric:      "0123456789",
+	CharsetSymbols:      "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!#%+,-.:=@^_~",
+}
+
+//go:embed wordlist.txt
+var wordlistData string
+
+var wordlist = strings.Fields(wordlistData)
+
+// Policy describes how a secret value is generated. The zero value generates
+// a 32 character alphanumeric string.
+type Policy struct {
+	// Mode is one of chars, passphrase, hex or base64.
+	Mode string `toml:"mode,omitempty"`
+	// Length is the number of characters in chars mode and the number of
+	// random bytes in hex and base64 mode.
+	Length int `toml:"length,omitempty"`
+	// Charset names a predefined character set for chars mode.
+	Charset string `toml:"charset,omitempty"`
+	// Chars is a custom character set for chars mode and overrides Charset.
+	Chars string `toml:"chars,omitempty"`
+	// Words is the number of words in passphrase mode.
+	Words int `toml:"words,omitempty"`
+	// Separator joins words in passphrase mode.
+	Separator string `toml:"separator,omitempty"`
+}
+
+// Validate reports whether p describes a usable policy.
+func (p Policy) Validate() error {
+	if p.Length < 0 {
+		return fmt.Errorf("length must be positive, got %d", p.Length)
+	}
+	if p.Words < 0 {
+		return fmt.Errorf("words must be positive, got %d", p.Words)
+	}
+
+	switch p.Mode {
+	case "", ModeChars, ModePassphrase, ModeHex, ModeBase64:
+	default:
+		return fmt.Errorf("unknown mode: %s", p.Mode)
+	}
+
+	if p.Chars == "" && p.Charset != "" {
+		if _, ok := charsets[p.Charset]; !ok {
+			return fmt.Errorf("unknown charset: %s", p.Charset)
+		}
+	}
+	return nil
+}
+
+// Generate creates a cryptographically random value according to p.
+func Generate(p Policy) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+
+	length := p.Length
+	if length == 0 {
+		length = DefaultLength
+	}
+
+	switch p.Mode {
+	case ModePassphrase:
+		words := p.Words
+		if words == 0 {
+			words = DefaultWords
+		}
+		separator := p.Separator
+		if separator == "" {
+			separator = DefaultSeparator
+		}
+		return passphrase(words, separator)
+	case ModeHex:
+		b, err := randomBytes(length)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(b), nil
+	case ModeBase64:
+		b, err := randomBytes(length)
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	default:
+		chars := p.Chars
+		if chars == "" {
+			charset := p.Charset
+			if charset == "" {
+				charset = CharsetAlphanumeric
+			}
+			chars = charsets[charset]
+		}
+		return randomChars([]rune(chars), length)
+	}
+}
+
+func randomChars(chars []rune, length int) (string, error) {
+	if len(chars) == 0 {
+		return "", fmt.Errorf("character set is empty")
+	}
+
+	var sb strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := randomIndex(len(chars))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteRune(chars[n])
+	}
+	return sb.String(), nil
+}
+
+func passphrase(words int, separator string) (string, error) {
+	parts := make([]string, words)
+	for i := range parts {
+		n, err := randomIndex(len(wordlist))
+		if err != nil {
+			return "", err
+		}
+		parts[i] = wordlist[n]
+	}
+	return strings.Join(parts, separator), nil
+}
+
+func randomIndex(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, fmt.Errorf("read random: %w", err)
+	}
+	return int(n.Int64()), nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("read random: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,105 @@
+package secretgen
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestGenerateDefault(t *testing.T) {
+	t.Parallel()
+
+	v, err := Generate(Policy{})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(v) != DefaultLength {
+		t.Fatalf("expected length %d, got %d", DefaultLength, len(v))
+	}
+	for _, r := range v {
+		if !strings.ContainsRune(charsets[CharsetAlphanumeric], r) {
+			t.Fatalf("unexpected character %q in %q", r, v)
+		}
+	}
+}
+
+func TestGenerateCustomChars(t *testing.T) {
+	t.Parallel()
+
+	v, err := Generate(Policy{Length: 16, Chars: "ab"})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(v) != 16 || strings.Trim(v, "ab") != "" {
+		t.Fatalf("unexpected value %q", v)
+	}
+}
+
+func TestGenerateCharsets(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		CharsetAlpha:      "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+		CharsetLower:      "abcdefghijklmnopqrstuvwxyz",
+		CharsetLowerAlnum: "abcdefghijklmnopqrstuvwxyz0123456789",
+		CharsetNumeric:    "0123456789",
+	}
+	for charset, chars := range tests {
+		v, err := Generate(Policy{Length: 200, Charset: charset})
+		if err != nil {
+			t.Fatalf("generate %s: %v", charset, err)
+		}
+		if len(v) != 200 || strings.Trim(v, chars) != "" {
+			t.Errorf("%s: value %q has characters outside %q", charset, v, chars)
+		}
+	}
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	t.Parallel()
+
+	v, err := Generate(Policy{Mode: ModePassphrase, Words: 5, Separator: "."})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	words := strings.Split(v, ".")
+	if len(words) != 5 {
+		t.Fatalf("expected 5 words, got %q", v)
+	}
+}
+
+func TestGenerateEncodings(t *testing.T) {
+	t.Parallel()
+
+	h, err := Generate(Policy{Mode: ModeHex, Length: 16})
+	if err != nil {
+		t.Fatalf("generate hex: %v", err)
+	}
+	if b, err := hex.DecodeString(h); err != nil || len(b) != 16 {
+		t.Fatalf("expected 16 hex encoded bytes, got %q", h)
+	}
+
+	b64, err := Generate(Policy{Mode: ModeBase64, Length: 24})
+	if err != nil {
+		t.Fatalf("generate base64: %v", err)
+	}
+	if b, err := base64.StdEncoding.DecodeString(b64); err != nil || len(b) != 24 {
+		t.Fatalf("expected 24 base64 encoded bytes, got %q", b64)
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, p := range []Policy{
+		{Mode: "bogus"},
+		{Charset: "bogus"},
+		{Length: -1},
+		{Mode: ModePassphrase, Words: -1},
+	} {
+		if _, err := Generate(p); err == nil {
+			t.Fatalf("expected error for %#v", p)
+		}
+	}
+}